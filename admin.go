@@ -0,0 +1,248 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var adminToken = GetEnv("ADMIN_TOKEN", "") // empty disables the admin API
+
+// AdminAPI lets organizers inspect and override the rooms (see ServeHTTP).
+// Every request needs the token, as "Authorization: Bearer <token>".
+type AdminAPI struct {
+	Token     string
+	Scheduler *Scheduler
+	States    *RoomStates
+	Shifts    *ShiftStore
+	Replan    func()                // called after the shifts change (see replanSchedule)
+	Location  func() *time.Location // venue timezone of the shift times. Defaults to the local one
+}
+
+// AdminRoom is the state of a room, as returned by the admin API
+type AdminRoom struct {
+	ID         int       `json:"room_id"`
+	Current    *RoomInfo `json:"current"` // what the room is showing
+	Next       *Job      `json:"next"`    // next scheduled update
+	Overridden bool      `json:"overridden"`
+}
+
+// ServeHTTP routes the admin API:
+//
+//	GET  /admin/rooms                  current/next RoomInfo of every room
+//	GET  /admin/rooms/{id}             current/next RoomInfo of the room
+//	GET  /admin/jobs                   pending scheduled updates
+//	POST /admin/rooms/{id}/override    send the RoomInfo fields in the body, holding the schedule
+//	POST /admin/rooms/{id}/resume      resume the schedule
+//	POST /admin/rooms/{id}/shift       shift the room events, e.g. {"minutes": 10, "from": "2019-10-10T14:00:00+01:00"} or "from": "14:00" (venue time, today)
+//	GET  /admin/shifts                 shifts applied
+//	DELETE /admin/shifts/{id|last}     undo a shift
+func (a *AdminAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if !a.authorized(r) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	switch {
+	case len(parts) == 2 && parts[1] == "jobs" && r.Method == http.MethodGet:
+		writeJSON(w, a.Scheduler.Pending())
+	case len(parts) == 2 && parts[1] == "rooms" && r.Method == http.MethodGet:
+		writeJSON(w, a.rooms())
+	case len(parts) == 2 && parts[1] == "shifts" && r.Method == http.MethodGet:
+		writeJSON(w, a.Shifts.List())
+	case len(parts) == 3 && parts[1] == "shifts" && r.Method == http.MethodDelete:
+		a.undoShift(w, parts[2])
+	case len(parts) >= 3 && parts[1] == "rooms":
+		roomID, err := strconv.Atoi(parts[2])
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		a.serveRoom(w, r, roomID, parts[3:])
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (a *AdminAPI) serveRoom(w http.ResponseWriter, r *http.Request, roomID int, action []string) {
+	switch {
+	case len(action) == 0 && r.Method == http.MethodGet:
+		writeJSON(w, a.room(roomID, a.Scheduler.Pending()))
+	case len(action) == 1 && action[0] == "override" && r.Method == http.MethodPost:
+		a.override(w, r, roomID)
+	case len(action) == 1 && action[0] == "resume" && r.Method == http.MethodPost:
+		a.resume(w, roomID)
+	case len(action) == 1 && action[0] == "shift" && r.Method == http.MethodPost:
+		a.shift(w, r, roomID)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// authorized checks the bearer token. It is not accepted in the URL, which ends up in logs and browser history.
+func (a *AdminAPI) authorized(r *http.Request) bool {
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "Bearer ") {
+		return false
+	}
+	token := strings.TrimPrefix(auth, "Bearer ")
+	return a.Token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(a.Token)) == 1
+}
+
+func (a *AdminAPI) room(roomID int, pending []Job) AdminRoom {
+	room := AdminRoom{ID: roomID, Overridden: a.States.Overridden(roomID)}
+	if shown, ok := a.States.Get(roomID); ok {
+		room.Current = &shown.Info
+	}
+	for _, job := range pending {
+		if job.RoomID == roomID {
+			next := job
+			room.Next = &next
+			break
+		}
+	}
+	return room
+}
+
+func (a *AdminAPI) rooms() []AdminRoom {
+	pending := a.Scheduler.Pending()
+
+	seen := make(map[int]bool)
+	roomIDs := a.States.Rooms()
+	for _, roomID := range roomIDs {
+		seen[roomID] = true
+	}
+	for _, job := range pending {
+		if !seen[job.RoomID] {
+			seen[job.RoomID] = true
+			roomIDs = append(roomIDs, job.RoomID)
+		}
+	}
+
+	rooms := make([]AdminRoom, 0, len(roomIDs))
+	for _, roomID := range roomIDs {
+		rooms = append(rooms, a.room(roomID, pending))
+	}
+	return rooms
+}
+
+// override sends the current RoomInfo of the room, with the fields in the request body replaced
+func (a *AdminAPI) override(w http.ResponseWriter, r *http.Request, roomID int) {
+	info := RoomInfo{}
+	if shown, ok := a.States.Get(roomID); ok {
+		info = shown.Info
+	}
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := json.Unmarshal(body, &info); err != nil {
+		http.Error(w, "invalid RoomInfo: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	info.ID = roomID
+
+	log.Printf("ADMIN: overriding room %v: %v\n", roomID, string(body))
+	a.States.Override(roomID)
+	a.Scheduler.Schedule(Job{
+		Key:      strconv.Itoa(roomID) + "/override",
+		RoomID:   roomID,
+		At:       a.Scheduler.Clock.Now(),
+		Info:     info,
+		Override: true,
+	})
+	w.WriteHeader(http.StatusAccepted)
+	writeJSON(w, info)
+}
+
+// resume sends the update the schedule has for the room now
+func (a *AdminAPI) resume(w http.ResponseWriter, roomID int) {
+	if !a.States.Overridden(roomID) {
+		http.Error(w, "room is not overridden", http.StatusConflict)
+		return
+	}
+	a.Scheduler.Cancel(strconv.Itoa(roomID) + "/override")
+
+	log.Printf("ADMIN: resuming the schedule on room %v\n", roomID)
+	job, ok := a.States.Resume(roomID)
+	if ok {
+		job.Key = strconv.Itoa(roomID) + "/resume"
+		job.At = a.Scheduler.Clock.Now()
+		job.Attempt, job.Seq, job.publisher = 0, 0, nil
+		job.Override = true // manual, so schedule reloads leave it alone
+		a.Scheduler.Schedule(job)
+	}
+	w.WriteHeader(http.StatusAccepted)
+	writeJSON(w, a.room(roomID, a.Scheduler.Pending()))
+}
+
+// shift delays the room events from the given time (see parseShiftFrom), in the venue timezone and bot clock
+func (a *AdminAPI) shift(w http.ResponseWriter, r *http.Request, roomID int) {
+	var request struct {
+		Minutes int    `json:"minutes"`
+		From    string `json:"from"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "invalid shift: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if request.Minutes == 0 {
+		http.Error(w, "invalid shift: minutes is required", http.StatusBadRequest)
+		return
+	}
+	loc := time.Local
+	if a.Location != nil {
+		loc = a.Location()
+	}
+	from, err := parseShiftFrom(request.From, a.Scheduler.Clock.Now().In(loc))
+	if err != nil {
+		http.Error(w, "invalid shift: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	shift := a.Shifts.Add(RoomShift{RoomID: roomID, From: from, Minutes: request.Minutes})
+	log.Printf("ADMIN: shifting room %v by %v minutes from %v (shift %v)\n", roomID, shift.Minutes, shift.From, shift.ID)
+	a.Replan()
+	w.WriteHeader(http.StatusCreated)
+	writeJSON(w, shift)
+}
+
+// undoShift removes a shift (by ID, or the "last" one)
+func (a *AdminAPI) undoShift(w http.ResponseWriter, idStr string) {
+	id := 0
+	if idStr != "last" {
+		var err error
+		if id, err = strconv.Atoi(idStr); err != nil || id <= 0 {
+			http.Error(w, "invalid shift id", http.StatusNotFound)
+			return
+		}
+	}
+
+	shift, ok := a.Shifts.Undo(id)
+	if !ok {
+		http.Error(w, "shift not found", http.StatusNotFound)
+		return
+	}
+	log.Printf("ADMIN: undoing shift %v of room %v\n", shift.ID, shift.RoomID)
+	a.Replan()
+	writeJSON(w, shift)
+}
+
+// writeJSON writes v as the JSON response (after any WriteHeader)
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		log.Println("Could not marshal the response", err)
+		return
+	}
+	w.Write(data)
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestAdminAPI() *AdminAPI {
+	return &AdminAPI{Token: "secret", Scheduler: NewScheduler(func(Job) {}), States: NewRoomStates()}
+}
+
+func adminRequest(api *AdminAPI, method, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer secret")
+	rec := httptest.NewRecorder()
+	api.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestAdminAPIAuthorization(t *testing.T) {
+	api := newTestAdminAPI()
+
+	for _, path := range []string{"/admin/rooms", "/admin/rooms?token=secret"} {
+		rec := httptest.NewRecorder()
+		api.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != http.StatusUnauthorized {
+			t.Errorf("Expected 401 for %v. Got: %v", path, rec.Code)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/rooms", nil)
+	req.Header.Set("Authorization", "Bearer wrong")
+	rec := httptest.NewRecorder()
+	api.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 with a wrong token. Got: %v", rec.Code)
+	}
+
+	req.Header.Set("Authorization", "Bearer secret")
+	rec = httptest.NewRecorder()
+	api.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected 200 with the token. Got: %v", rec.Code)
+	}
+}
+
+func TestAdminAPIRoomsAndJobs(t *testing.T) {
+	api := newTestAdminAPI()
+	api.States.Set(Job{RoomID: 1, Info: RoomInfo{ID: 1, CurrentTitle: "Now"}})
+	api.Scheduler.Schedule(Job{Key: "1/next", RoomID: 1, At: time.Now().Add(time.Hour), Info: RoomInfo{ID: 1, CurrentTitle: "Next"}})
+	api.Scheduler.Schedule(Job{Key: "2/next", RoomID: 2, At: time.Now().Add(time.Hour), Info: RoomInfo{ID: 2, CurrentTitle: "Later"}})
+
+	var rooms []AdminRoom
+	json.Unmarshal(adminRequest(api, http.MethodGet, "/admin/rooms", "").Body.Bytes(), &rooms)
+	if len(rooms) != 2 {
+		t.Fatalf("Expected 2 rooms. Got: %v", rooms)
+	}
+	if rooms[0].Current == nil || rooms[0].Current.CurrentTitle != "Now" || rooms[0].Next.Info.CurrentTitle != "Next" {
+		t.Errorf("Unexpected room 1: %+v", rooms[0])
+	}
+	if rooms[1].Current != nil || rooms[1].Next.Key != "2/next" {
+		t.Errorf("Unexpected room 2: %+v", rooms[1])
+	}
+
+	var jobs []Job
+	json.Unmarshal(adminRequest(api, http.MethodGet, "/admin/jobs", "").Body.Bytes(), &jobs)
+	if len(jobs) != 2 {
+		t.Errorf("Expected 2 pending jobs. Got: %v", jobs)
+	}
+
+	if rec := adminRequest(api, http.MethodGet, "/admin/rooms/x", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an invalid room. Got: %v", rec.Code)
+	}
+}
+
+func TestAdminAPIOverrideAndResume(t *testing.T) {
+	api := newTestAdminAPI()
+	api.States.Set(Job{Key: "1/talk", RoomID: 1, Info: RoomInfo{ID: 1, RoomName: "Room1", CurrentTitle: "Talk"}})
+
+	rec := adminRequest(api, http.MethodPost, "/admin/rooms/1/override", `{"title":"Talk delayed 10 min"}`)
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("Unexpected override response: %v %v", rec.Code, rec.Body)
+	}
+	job, ok := api.Scheduler.Get("1/override")
+	if !ok || !job.Override || job.Info.CurrentTitle != "Talk delayed 10 min" || job.Info.RoomName != "Room1" {
+		t.Errorf("Expected an override update keeping the other fields. Got: %+v", job)
+	}
+
+	// scheduled updates are held meanwhile
+	if !api.States.Hold(Job{Key: "1/next", RoomID: 1, Info: RoomInfo{ID: 1, CurrentTitle: "Next talk"}}) {
+		t.Error("Scheduled updates should be held while overridden")
+	}
+
+	if rec = adminRequest(api, http.MethodPost, "/admin/rooms/1/resume", ""); rec.Code != http.StatusAccepted {
+		t.Fatalf("Unexpected resume response: %v %v", rec.Code, rec.Body)
+	}
+	if _, ok := api.Scheduler.Get("1/override"); ok {
+		t.Error("Pending override should be cancelled")
+	}
+	if job, ok := api.Scheduler.Get("1/resume"); !ok || job.Info.CurrentTitle != "Next talk" {
+		t.Errorf("Expected the held update to be sent. Got: %+v", job)
+	}
+	if api.States.Overridden(1) {
+		t.Error("Room should not be overridden anymore")
+	}
+
+	if rec = adminRequest(api, http.MethodPost, "/admin/rooms/1/resume", ""); rec.Code != http.StatusConflict {
+		t.Errorf("Expected 409 resuming a room not overridden. Got: %v", rec.Code)
+	}
+}
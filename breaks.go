@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// RoomInfo.Phase of the gaps between events
+const phaseBreak = "break"
+
+// BreakMessage is the message of the gaps of at least MinMinutes
+type BreakMessage struct {
+	MinMinutes int
+	Message    *template.Template // sees a BreakData
+}
+
+// Breaks are the break messages, by ascending MinMinutes. Shorter gaps are not breaks.
+type Breaks []BreakMessage
+
+// BreakData is what the BreakMessage templates see
+type BreakData struct {
+	Minutes int    // of the gap
+	Time    string // HH:MM of the next event, in the venue timezone
+	Title   string // of the next event
+	Speaker string
+	Room    string
+}
+
+var eventBreaks = parseBreaksEnv("BREAK_MESSAGES", "")
+
+// ParseBreaks parses a "minutes=template;minutes=template" list,
+// e.g. "15=Break - next talk at {{.Time}};60=Lunch - back at {{.Time}}"
+func ParseBreaks(config string) (Breaks, error) {
+	messages := make(map[string]string)
+	for _, entry := range strings.Split(config, ";") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("error: invalid break message %q. Expected minutes=template", entry)
+		}
+		messages[parts[0]] = parts[1]
+	}
+	return newBreaks(messages)
+}
+
+// newBreaks parses the break message templates by minimum gap minutes
+func newBreaks(messages map[string]string) (Breaks, error) {
+	var breaks Breaks
+	for key, text := range messages {
+		minutes, err := strconv.Atoi(strings.TrimSpace(key))
+		if err != nil || minutes <= 0 {
+			return nil, fmt.Errorf("error: invalid break minutes %q. Expected a number greater than 0", key)
+		}
+		message, err := template.New("break").Funcs(templateFuncs).Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("error: invalid break message %q: %v", text, err)
+		}
+		breaks = append(breaks, BreakMessage{MinMinutes: minutes, Message: message})
+	}
+	sort.Slice(breaks, func(i, j int) bool { return breaks[i].MinMinutes < breaks[j].MinMinutes })
+	return breaks, nil
+}
+
+func parseBreaksEnv(key, fallback string) Breaks {
+	breaks, err := ParseBreaks(GetEnv(key, fallback))
+	if err != nil {
+		log.Printf("WARNING: Invalid %v, breaks are disabled. %v\n", key, err)
+		return nil
+	}
+	return breaks
+}
+
+// message returns the message of a gap, nil if it is not a break
+func (b Breaks) message(gap time.Duration) *template.Template {
+	var message *template.Template
+	for _, brk := range b {
+		if gap >= time.Duration(brk.MinMinutes)*time.Minute {
+			message = brk.Message
+		}
+	}
+	return message
+}
+
+// Plan returns the break update sent when the previous event ends, if job (sent at that
+// time) waits long enough for its event. Gaps across days are not breaks.
+func (b Breaks) Plan(job Job, hasPrevious bool, loc *time.Location) (Job, bool) {
+	gap := job.Start.Sub(job.At)
+	if !hasPrevious || gap <= 0 || job.At.In(loc).Format("2006-01-02") != job.Start.In(loc).Format("2006-01-02") {
+		return Job{}, false
+	}
+	message := b.message(gap)
+	if message == nil {
+		return Job{}, false
+	}
+
+	info := job.Info
+	notice := renderNotice(message, BreakData{
+		Minutes: int(gap / time.Minute),
+		Time:    info.CurrentTime,
+		Title:   info.CurrentTitle,
+		Speaker: info.CurrentSpeaker,
+		Room:    info.RoomName,
+	})
+	brk := job
+	brk.Key += "/break"
+	// the room name and auto loop are the ones of the event (maybe from templates)
+	brk.Info = RoomInfo{
+		ID:           info.ID,
+		RoomName:     info.RoomName,
+		CurrentTitle: notice,
+		NextTitle:    info.CurrentTitle,
+		NextSpeaker:  info.CurrentSpeaker,
+		NextTime:     info.CurrentTime,
+		AutoLoopSec:  info.AutoLoopSec,
+		Phase:        phaseBreak,
+		Notice:       notice,
+		StartsAt:     job.Start.Format(time.RFC3339),
+	}
+	return brk, true
+}
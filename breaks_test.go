@@ -0,0 +1,81 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseBreaks(t *testing.T) {
+	breaks, err := ParseBreaks("60=Lunch - back at {{.Time}}; 15=Break - next talk at {{.Time}}")
+	if err != nil || len(breaks) != 2 || breaks[0].MinMinutes != 15 || breaks[1].MinMinutes != 60 {
+		t.Fatalf("Unexpected breaks: %+v, %v", breaks, err)
+	}
+	if message := breaks.message(10 * time.Minute); message != nil {
+		t.Error("Gaps shorter than the smallest break are not breaks")
+	}
+	if message := breaks.message(30 * time.Minute); message != breaks[0].Message {
+		t.Error("A 30 minutes gap should use the 15 minutes message")
+	}
+	if message := breaks.message(90 * time.Minute); message != breaks[1].Message {
+		t.Error("A 90 minutes gap should use the 60 minutes message")
+	}
+
+	if breaks, err := ParseBreaks(""); err != nil || breaks != nil {
+		t.Errorf("No config means no breaks. Got: %v, %v", breaks, err)
+	}
+	for _, config := range []string{"Break", "x=Break", "0=Break", "15=Break {{.Time"} {
+		if _, err := ParseBreaks(config); err == nil {
+			t.Errorf("%v should fail", config)
+		}
+	}
+}
+
+func TestPlanBreaks(t *testing.T) {
+	schedule := Schedule{Days: []Day{
+		{Date: "2019-10-10", Rooms: []Room{
+			{ID: 1, Name: "Room1", Events: []Event{
+				{GUID: "abc1", Title: "Event1", Date: "2019-10-10T10:00:00Z", Duration: "00:30"},
+				{GUID: "abc2", Title: "Event2", Date: "2019-10-10T10:35:00Z", Duration: "00:55"},
+				{GUID: "abc3", Title: "Event3", Date: "2019-10-10T13:00:00Z", Duration: "00:30", Persons: []Person{{Name: "PersonName3"}}},
+			}},
+		}},
+		{Date: "2019-10-11", Rooms: []Room{
+			{ID: 1, Name: "Room1", Events: []Event{
+				{GUID: "cde1", Title: "OtherEvent1", Date: "2019-10-11T10:00:00Z", Duration: "00:30"},
+			}},
+		}},
+	}}
+	defer func(b Breaks) { eventBreaks = b }(eventBreaks)
+	eventBreaks, _ = ParseBreaks("15=Break - next talk at {{.Time}};60=Lunch ({{.Minutes}} minutes) - back with {{.Speaker}}")
+
+	now := time.Date(2019, 10, 10, 9, 0, 0, 0, time.UTC)
+	jobs := planEventUpdatesAt(schedule, now)
+	expected := []struct {
+		key, at, title string
+	}{
+		{"1/abc1", "2019-10-10 09:00", "Event1"},
+		{"1/abc2", "2019-10-10 10:30", "Event2"}, // 5 minutes are not a break
+		{"1/abc3/break", "2019-10-10 11:30", "Lunch (90 minutes) - back with PersonName3"},
+		{"1/abc3", "2019-10-10 13:00", "Event3"},
+		{"1/cde1", "2019-10-10 13:30", "OtherEvent1"}, // nights are not breaks
+	}
+	if len(jobs) != len(expected) {
+		t.Fatalf("Unexpected jobs: %+v", jobs)
+	}
+	for i, e := range expected {
+		if job := jobs[i]; job.Key != e.key || job.At.Format("2006-01-02 15:04") != e.at || job.Info.CurrentTitle != e.title {
+			t.Errorf("Unexpected job %v. Got: %v %v %v", i, job.Key, job.At.Format("2006-01-02 15:04"), job.Info.CurrentTitle)
+		}
+	}
+	if info := jobs[2].Info; info.Phase != phaseBreak || info.Notice != info.CurrentTitle || info.NextTitle != "Event3" || info.NextTime != "13:00" || info.StartsAt != "2019-10-10T13:00:00Z" {
+		t.Errorf("The break should show the next event. Got: %+v", info)
+	}
+
+	// with pre-roll, the event is announced after the break
+	defer func(p Preroll) { eventPreroll = p }(eventPreroll)
+	eventPreroll = Preroll{Minutes: []int{5}, Message: parseTemplateEnv("PREROLL_MESSAGE", defaultPrerollMessage)}
+	jobs = planEventUpdatesAt(schedule, now)
+	if len(jobs) != 11 || jobs[5].Key != "1/abc3/break" || jobs[6].Key != "1/abc3/soon-5" || jobs[7].Key != "1/abc3" {
+		t.Errorf("Unexpected jobs with pre-roll: %+v", jobs)
+	}
+}
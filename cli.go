@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// commands are the subcommands of the bot. Without one, the bot runs the schedule.
+var commands = map[string]func(args []string) int{
+	"dry-run":    dryRunCommand,
+	"shift":      shiftCommand,
+	"undo-shift": undoShiftCommand,
+	"validate":   validateCommand,
+}
+
+// runCommand runs the subcommand, returning the exit code
+func runCommand(name string, args []string) int {
+	command, ok := commands[name]
+	if !ok {
+		names := make([]string, 0, len(commands))
+		for n := range commands {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		fmt.Fprintf(os.Stderr, "unknown command %q. Available commands: %v\n", name, strings.Join(names, ", "))
+		return 2
+	}
+	return command(args)
+}
+
+// defaultAdminURL is the admin API of a bot running with the same SERVER_ADDR
+func defaultAdminURL() string {
+	addr := serverAddr
+	if addr == "" {
+		addr = ":8080"
+	}
+	if strings.HasPrefix(addr, ":") {
+		addr = "localhost" + addr
+	}
+	return "http://" + addr
+}
+
+// parseShiftFrom parses HH:MM (the day and timezone of now) or RFC3339. Empty means now.
+func parseShiftFrom(from string, now time.Time) (time.Time, error) {
+	if from == "" {
+		return now, nil
+	}
+	if t, err := time.ParseInLocation("15:04", from, now.Location()); err == nil {
+		return time.Date(now.Year(), now.Month(), now.Day(), t.Hour(), t.Minute(), 0, 0, now.Location()), nil
+	}
+	return time.Parse(time.RFC3339, from)
+}
+
+func shiftCommand(args []string) int {
+	flags := flag.NewFlagSet("shift", flag.ContinueOnError)
+	roomID := flags.Int("room", 0, "room ID")
+	minutes := flags.Int("minutes", 0, "minutes to delay the events (negative to advance them)")
+	from := flags.String("from", "", "shift events from this time on (HH:MM today in the venue timezone, or RFC3339). Defaults to now")
+	server := flags.String("server", defaultAdminURL(), "URL of the running bot")
+	token := flags.String("token", adminToken, "admin token (defaults to ADMIN_TOKEN)")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *roomID == 0 || *minutes == 0 {
+		fmt.Fprintln(os.Stderr, "-room and -minutes are required")
+		flags.Usage()
+		return 2
+	}
+
+	// HH:MM is resolved by the bot, on its day (maybe simulated) and venue timezone
+	if _, err := parseShiftFrom(*from, time.Now()); err != nil {
+		fmt.Fprintln(os.Stderr, "invalid -from:", err)
+		return 2
+	}
+	body, _ := json.Marshal(map[string]interface{}{"minutes": *minutes, "from": *from})
+
+	return callAdminAPI(http.MethodPost, *server+"/admin/rooms/"+strconv.Itoa(*roomID)+"/shift", *token, body)
+}
+
+func undoShiftCommand(args []string) int {
+	flags := flag.NewFlagSet("undo-shift", flag.ContinueOnError)
+	id := flags.Int("id", 0, "shift ID to undo. Defaults to the last one")
+	server := flags.String("server", defaultAdminURL(), "URL of the running bot")
+	token := flags.String("token", adminToken, "admin token (defaults to ADMIN_TOKEN)")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	idStr := "last"
+	if *id != 0 {
+		idStr = strconv.Itoa(*id)
+	}
+	return callAdminAPI(http.MethodDelete, *server+"/admin/shifts/"+idStr, *token, nil)
+}
+
+// callAdminAPI does the request and prints the response
+func callAdminAPI(method, URL, token string, body []byte) int {
+	req, err := http.NewRequest(method, URL, bytes.NewReader(body))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer resp.Body.Close()
+	response, _ := ioutil.ReadAll(resp.Body)
+
+	if resp.StatusCode >= 300 {
+		fmt.Fprintf(os.Stderr, "%v: %s\n", resp.Status, response)
+		return 1
+	}
+	fmt.Println(string(response))
+	return 0
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var simulateStart = GetEnv("SIMULATE_START", "")  // virtual start time, e.g. 2019-10-10T08:00 (venue timezone) or RFC3339
+var simulateSpeed = GetEnv("SIMULATE_SPEED", "1") // e.g. 60 replays an hour per minute
+
+// clock is the time of the bot (see NewClockFromConfig)
+var clock Clock = RealClock{}
+
+// Clock tells the time and makes timers
+type Clock interface {
+	Now() time.Time
+	NewTimer(d time.Duration) ClockTimer
+}
+
+// ClockTimer is a time.Timer of a Clock
+type ClockTimer interface {
+	C() <-chan time.Time
+	Stop() bool
+	Reset(d time.Duration)
+}
+
+// RealClock is the wall clock
+type RealClock struct{}
+
+// Now returns time.Now()
+func (RealClock) Now() time.Time { return time.Now() }
+
+// NewTimer returns a time.Timer
+func (RealClock) NewTimer(d time.Duration) ClockTimer { return realTimer{time.NewTimer(d)} }
+
+type realTimer struct{ timer *time.Timer }
+
+func (t realTimer) C() <-chan time.Time   { return t.timer.C }
+func (t realTimer) Stop() bool            { return t.timer.Stop() }
+func (t realTimer) Reset(d time.Duration) { t.timer.Reset(d) }
+
+// SimulatedClock runs from a virtual start time, speed times faster than the real time.
+// With speed 0 it only moves on Advance, for deterministic tests.
+type SimulatedClock struct {
+	mu       sync.Mutex
+	speed    float64
+	base     time.Time // virtual time at realBase
+	realBase time.Time
+	timers   map[*simulatedTimer]struct{}
+}
+
+// NewSimulatedClock creates a clock starting now at start
+func NewSimulatedClock(start time.Time, speed float64) *SimulatedClock {
+	return &SimulatedClock{speed: speed, base: start, realBase: time.Now(), timers: make(map[*simulatedTimer]struct{})}
+}
+
+// Now returns the virtual time
+func (c *SimulatedClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now()
+}
+
+func (c *SimulatedClock) now() time.Time {
+	elapsed := time.Since(c.realBase)
+	return c.base.Add(time.Duration(float64(elapsed) * c.speed))
+}
+
+// clockDuration returns how much time passes on c during the real duration d
+func clockDuration(c Clock, d time.Duration) time.Duration {
+	if sc, ok := c.(*SimulatedClock); ok && sc.speed > 0 {
+		return time.Duration(float64(d) * sc.speed)
+	}
+	return d
+}
+
+// Advance moves the virtual time forward, firing the timers due
+func (c *SimulatedClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	c.base = c.base.Add(d)
+	c.fireDue()
+	c.mu.Unlock()
+}
+
+// NewTimer creates a timer firing after d of virtual time
+func (c *SimulatedClock) NewTimer(d time.Duration) ClockTimer {
+	t := &simulatedTimer{clock: c, c: make(chan time.Time, 1)}
+	t.Reset(d)
+	return t
+}
+
+// fireDue sends the virtual time to the timers due. c.mu must be held.
+func (c *SimulatedClock) fireDue() {
+	now := c.now()
+	for t := range c.timers {
+		if !t.deadline.After(now) {
+			delete(c.timers, t)
+			if t.real != nil {
+				t.real.Stop()
+			}
+			select {
+			case t.c <- now:
+			default:
+			}
+		}
+	}
+}
+
+type simulatedTimer struct {
+	clock    *SimulatedClock
+	c        chan time.Time
+	deadline time.Time
+	real     *time.Timer // wakes the timer up in real time, when the clock runs
+}
+
+func (t *simulatedTimer) C() <-chan time.Time { return t.c }
+
+func (t *simulatedTimer) Stop() bool {
+	c := t.clock
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, active := c.timers[t]
+	delete(c.timers, t)
+	if t.real != nil {
+		t.real.Stop()
+	}
+	return active
+}
+
+func (t *simulatedTimer) Reset(d time.Duration) {
+	c := t.clock
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t.deadline = c.now().Add(d)
+	c.timers[t] = struct{}{}
+	if c.speed > 0 {
+		if t.real != nil {
+			t.real.Stop()
+		}
+		t.real = time.AfterFunc(time.Duration(float64(d)/c.speed), t.wake)
+	}
+	c.fireDue()
+}
+
+// wake fires the timer, or sleeps again if the real timer was early
+func (t *simulatedTimer) wake() {
+	c := t.clock
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, active := c.timers[t]; !active {
+		return
+	}
+	if remaining := t.deadline.Sub(c.now()); remaining > 0 {
+		t.real = time.AfterFunc(time.Duration(float64(remaining)/c.speed)+time.Millisecond, t.wake)
+		return
+	}
+	c.fireDue()
+}
+
+// NewClockFromConfig returns the RealClock, or a SimulatedClock when SIMULATE_START or
+// SIMULATE_SPEED are set. A start without zone is in the venue timezone. TEST_MODE replays
+// the schedule from its first event at an hour per second, unless they are set.
+func NewClockFromConfig(start, speed string, schedule Schedule) (Clock, error) {
+	if testMode {
+		if start == "" {
+			start = scheduleStart(schedule).Format(time.RFC3339)
+		}
+		if speed == "1" {
+			speed = "3600"
+		}
+	}
+
+	factor, err := strconv.ParseFloat(speed, 64)
+	if err != nil || factor <= 0 {
+		return nil, fmt.Errorf("error: invalid SIMULATE_SPEED %q. Expected a number greater than 0", speed)
+	}
+	if start == "" && factor == 1 {
+		return RealClock{}, nil
+	}
+
+	startTime := time.Now()
+	if start != "" {
+		if startTime, err = ParseEventTime(start, venueLocation(schedule.Conference)); err != nil {
+			return nil, fmt.Errorf("error: invalid SIMULATE_START %q: %v", start, err)
+		}
+	}
+	return NewSimulatedClock(startTime, factor), nil
+}
+
+// scheduleStart returns the start of the first event, or now if there is none
+func scheduleStart(schedule Schedule) time.Time {
+	var first time.Time
+	loc := venueLocation(schedule.Conference)
+	for _, day := range schedule.Days {
+		for _, room := range day.Rooms {
+			for _, event := range room.Events {
+				if start, err := ParseEventTime(event.Date, loc); err == nil && (first.IsZero() || start.Before(first)) {
+					first = start
+				}
+			}
+		}
+	}
+	if first.IsZero() {
+		return time.Now()
+	}
+	return first
+}
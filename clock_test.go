@@ -0,0 +1,194 @@
+package main
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSimulatedClockAdvance(t *testing.T) {
+	start := time.Date(2019, 10, 10, 8, 0, 0, 0, time.UTC)
+	c := NewSimulatedClock(start, 0)
+	timer := c.NewTimer(time.Hour)
+
+	c.Advance(30 * time.Minute)
+	select {
+	case <-timer.C():
+		t.Fatal("The timer should not fire before its time")
+	default:
+	}
+	if now := c.Now(); !now.Equal(start.Add(30 * time.Minute)) {
+		t.Errorf("Unexpected time. Got: %v", now)
+	}
+
+	c.Advance(30 * time.Minute)
+	select {
+	case at := <-timer.C():
+		if !at.Equal(start.Add(time.Hour)) {
+			t.Errorf("Unexpected timer time. Got: %v", at)
+		}
+	default:
+		t.Fatal("The timer should fire at its time")
+	}
+	if timer.Stop() {
+		t.Error("Stopping a fired timer should return false")
+	}
+
+	timer.Reset(time.Minute)
+	if !timer.Stop() {
+		t.Error("Stopping a pending timer should return true")
+	}
+	c.Advance(time.Hour)
+	select {
+	case <-timer.C():
+		t.Error("A stopped timer should not fire")
+	default:
+	}
+}
+
+func TestSimulatedClockSpeed(t *testing.T) {
+	start := time.Date(2019, 10, 10, 8, 0, 0, 0, time.UTC)
+	c := NewSimulatedClock(start, 3600) // an hour per second
+	timer := c.NewTimer(10 * time.Minute)
+
+	select {
+	case at := <-timer.C():
+		if at.Before(start.Add(10 * time.Minute)) {
+			t.Errorf("The timer fired early: %v", at)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("The timer should fire after 10 virtual minutes")
+	}
+}
+
+func TestSchedulerReplay(t *testing.T) {
+	loc, _ := time.LoadLocation("Europe/Lisbon")
+	schedule := Schedule{
+		Conference: Conference{TimeZoneName: "Europe/Lisbon"},
+		Days: []Day{{Date: "2019-10-10", Rooms: []Room{
+			{ID: 1, Name: "Room1", Events: []Event{
+				{GUID: "abc1", Title: "Event1", Date: "2019-10-10T10:00:00+01:00", Duration: "00:30"},
+				{GUID: "abc2", Title: "Event2", Date: "2019-10-10T11:00:00+01:00", Duration: "00:30"},
+				{GUID: "abc3", Title: "Event3", Date: "2019-10-10T12:00:00+01:00", Duration: "00:30"},
+			}},
+		}}},
+	}
+
+	c := NewSimulatedClock(time.Date(2019, 10, 10, 9, 0, 0, 0, loc), 0)
+	dispatched := make(chan Job, 10)
+	s := NewScheduler(func(job Job) { dispatched <- job })
+	s.Clock = c
+	for _, job := range planEventUpdatesAt(schedule, c.Now()) {
+		s.Schedule(job)
+	}
+	go s.Run()
+	defer s.Stop()
+
+	expect := func(title string) {
+		t.Helper()
+		select {
+		case job := <-dispatched:
+			if job.Info.CurrentTitle != title {
+				t.Errorf("Unexpected update at %v. Got: %v Expected: %v", c.Now(), job.Info.CurrentTitle, title)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%v was not sent at %v", title, c.Now())
+		}
+	}
+	expectNone := func() {
+		t.Helper()
+		select {
+		case job := <-dispatched:
+			t.Errorf("Unexpected update at %v: %v", c.Now(), job.Info.CurrentTitle)
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+
+	expect("Event1")
+	c.Advance(89 * time.Minute) // 10:29
+	expectNone()
+	c.Advance(time.Minute) // 10:30, Event1 ends
+	expect("Event2")
+	c.Advance(time.Hour) // 11:30
+	expect("Event3")
+	s.Wait()
+}
+
+func TestNewClockFromConfig(t *testing.T) {
+	schedule := Schedule{
+		Conference: Conference{TimeZoneName: "Europe/Lisbon"},
+		Days: []Day{{Rooms: []Room{
+			{Events: []Event{{Date: "2019-10-10T11:00:00+01:00"}, {Date: "2019-10-10T10:00:00+01:00"}}},
+		}}},
+	}
+	loc, _ := time.LoadLocation("Europe/Lisbon")
+
+	if c, err := NewClockFromConfig("", "1", schedule); err != nil || c != (RealClock{}) {
+		t.Errorf("No simulation should use the real clock. Got: %v, %v", c, err)
+	}
+	for _, speed := range []string{"0", "-1", "fast"} {
+		if _, err := NewClockFromConfig("", speed, schedule); err == nil {
+			t.Errorf("SIMULATE_SPEED %v should fail", speed)
+		}
+	}
+	if _, err := NewClockFromConfig("tomorrow", "60", schedule); err == nil {
+		t.Error("An invalid SIMULATE_START should fail")
+	}
+
+	c, err := NewClockFromConfig("2019-10-10T08:00", "60", schedule)
+	simulated, ok := c.(*SimulatedClock)
+	if err != nil || !ok || simulated.speed != 60 || simulated.Now().Before(time.Date(2019, 10, 10, 8, 0, 0, 0, loc)) {
+		t.Errorf("Unexpected simulated clock: %+v, %v", c, err)
+	}
+
+	testMode = true
+	defer func() { testMode = false }()
+	c, err = NewClockFromConfig("", "1", schedule)
+	simulated, ok = c.(*SimulatedClock)
+	if err != nil || !ok || simulated.speed != 3600 || !simulated.base.Equal(time.Date(2019, 10, 10, 10, 0, 0, 0, loc)) {
+		t.Errorf("TEST_MODE should replay from the first event. Got: %+v, %v", c, err)
+	}
+}
+
+// attemptsPublisher fails the first update, recording the real time of each attempt
+type attemptsPublisher struct {
+	mu       sync.Mutex
+	attempts []time.Time
+}
+
+func (p *attemptsPublisher) Publish(RoomInfo) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.attempts = append(p.attempts, time.Now())
+	if len(p.attempts) == 1 {
+		return errors.New("connection refused")
+	}
+	return nil
+}
+
+func TestRetryBackoffInRealTime(t *testing.T) {
+	defer func(c Clock, s *Scheduler, p Publisher, states *RoomStates, policy RetryPolicy) {
+		clock, scheduler, publisher, roomStates, updateRetryPolicy = c, s, p, states, policy
+	}(clock, scheduler, publisher, roomStates, updateRetryPolicy)
+
+	recorder := &attemptsPublisher{}
+	clock = NewSimulatedClock(time.Date(2019, 10, 10, 9, 0, 0, 0, time.UTC), 3600)
+	scheduler = NewScheduler(callEventUpdater)
+	publisher, roomStates = recorder, NewRoomStates()
+	updateRetryPolicy = RetryPolicy{MaxAttempts: 2, Backoff: 100 * time.Millisecond}
+
+	scheduler.Schedule(Job{Key: "1/abc1", RoomID: 1, At: clock.Now(), Info: RoomInfo{ID: 1, CurrentTitle: "Event1"}})
+	go scheduler.Run()
+	defer scheduler.Stop()
+	scheduler.Wait()
+
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	if len(recorder.attempts) != 2 {
+		t.Fatalf("Expected a retry. Got %v attempts", len(recorder.attempts))
+	}
+	if interval := recorder.attempts[1].Sub(recorder.attempts[0]); interval < 90*time.Millisecond {
+		t.Errorf("The retry should wait the backoff in real time, even at 3600x. Waited: %v", interval)
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+// RoomPlan is the updates a room would get, in order
+type RoomPlan struct {
+	RoomID  int             `json:"room_id"`
+	Room    string          `json:"room"`
+	Updates []PlannedUpdate `json:"updates"`
+}
+
+// PlannedUpdate is a RoomInfo, when it would be sent and the start of its event
+type PlannedUpdate struct {
+	At    time.Time `json:"at"`
+	Start time.Time `json:"start"`
+	Info  RoomInfo  `json:"info"`
+}
+
+// dispatchPlan groups the jobs planned at now (see planEventUpdatesAt) per room. Jobs already
+// due are sent at now. A non empty day (2006-01-02, in the venue location) keeps only the
+// updates of the events on that day.
+func dispatchPlan(jobs []Job, now time.Time, day string, roomID int, loc *time.Location) []RoomPlan {
+	plans := []RoomPlan{}
+	for _, job := range jobs {
+		if (day != "" && job.Start.In(loc).Format("2006-01-02") != day) || (roomID != 0 && job.RoomID != roomID) {
+			continue
+		}
+		if len(plans) == 0 || plans[len(plans)-1].RoomID != job.RoomID {
+			plans = append(plans, RoomPlan{RoomID: job.RoomID, Room: job.Info.RoomName})
+		}
+		if job.At.Before(now) {
+			job.At = now
+		}
+		plan := &plans[len(plans)-1]
+		plan.Updates = append(plan.Updates, PlannedUpdate{At: job.At.In(loc), Start: job.Start.In(loc), Info: job.Info})
+	}
+	return plans
+}
+
+// writeDispatchPlan writes the plan as a table, a room after the other
+func writeDispatchPlan(w io.Writer, plans []RoomPlan) {
+	table := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(table, "ROOM\tAT\tTIME\tTITLE\tSPEAKER\tNEXT\tNOTICE")
+	for i, plan := range plans {
+		if i > 0 {
+			fmt.Fprintln(table, "\t\t\t\t\t\t")
+		}
+		for _, update := range plan.Updates {
+			info := update.Info
+			next := ""
+			if info.NextTitle != "" {
+				next = info.NextTime + " " + info.NextTitle
+			}
+			fmt.Fprintf(table, "%v %v\t%v\t%v\t%v\t%v\t%v\t%v\n", plan.RoomID, plan.Room, update.At.Format("2006-01-02 15:04"),
+				info.CurrentTime, info.CurrentTitle, info.CurrentSpeaker, next, info.Notice)
+		}
+	}
+	table.Flush()
+}
+
+// parseDryRunAt parses the time to plan from: RFC3339, or 2006-01-02T15:04 in the venue location.
+// Empty means the start of day, or now.
+func parseDryRunAt(at, day string, loc *time.Location, now time.Time) (time.Time, error) {
+	switch {
+	case at != "":
+		if t, err := time.ParseInLocation("2006-01-02T15:04", at, loc); err == nil {
+			return t, nil
+		}
+		return time.Parse(time.RFC3339, at)
+	case day != "":
+		return time.ParseInLocation("2006-01-02", day, loc)
+	}
+	return now, nil
+}
+
+func dryRunCommand(args []string) int {
+	flags := flag.NewFlagSet("dry-run", flag.ContinueOnError)
+	format := flags.String("format", "table", "output format: table or json")
+	day := flags.String("day", "", "only show the updates of the events on this day (2006-01-02)")
+	roomID := flags.Int("room", 0, "only show this room ID")
+	at := flags.String("at", "", "plan as if it was this time (RFC3339, or 2006-01-02T15:04 in the venue timezone). Defaults to the start of -day, or now")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *format != "table" && *format != "json" {
+		fmt.Fprintf(os.Stderr, "invalid -format %q: expected table or json\n", *format)
+		return 2
+	}
+
+	keepScheduleCache()
+	schedule, err := loadSchedule()
+	if err == nil {
+		err = loadTemplates()
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	loc := venueLocation(schedule.Conference)
+	now, err := parseDryRunAt(*at, *day, loc, clock.Now())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "invalid -at or -day:", err)
+		return 2
+	}
+
+	plans := dispatchPlan(catchUpUpdates(planEventUpdatesAt(roomShifts.Apply(schedule), now), now), now, *day, *roomID, loc)
+
+	if *format == "json" {
+		data, _ := json.MarshalIndent(plans, "", "  ")
+		fmt.Println(string(data))
+	} else {
+		writeDispatchPlan(os.Stdout, plans)
+	}
+	return 0
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDispatchPlan(t *testing.T) {
+	loc, _ := time.LoadLocation("Europe/Lisbon")
+	schedule := Schedule{
+		Conference: Conference{TimeZoneName: "Europe/Lisbon"},
+		Days: []Day{
+			{Date: "2019-10-10", Rooms: []Room{
+				{ID: 1, Name: "Room1", Events: []Event{
+					{GUID: "abc1", Title: "Event1", Date: "2019-10-10T10:00:00+01:00", Duration: "00:30", Persons: []Person{{Name: "PersonName1"}}},
+					{GUID: "abc2", Title: "Event2", Date: "2019-10-10T11:00:00+01:00", Duration: "00:30"},
+				}},
+				{ID: 2, Name: "Room2", Events: []Event{
+					{GUID: "abc3", Title: "Event3", Date: "2019-10-10T10:00:00+01:00", Duration: "01:00"},
+				}},
+			}},
+			{Date: "2019-10-11", Rooms: []Room{
+				{ID: 1, Name: "Room1", Events: []Event{
+					{GUID: "cde1", Title: "OtherEvent1", Date: "2019-10-11T10:00:00+01:00", Duration: "00:30"},
+				}},
+			}},
+		},
+	}
+
+	now, err := parseDryRunAt("", "2019-10-11", loc, time.Now())
+	if err != nil || !now.Equal(time.Date(2019, 10, 11, 0, 0, 0, 0, loc)) {
+		t.Fatalf("-day should plan from the start of the day. Got: %v, %v", now, err)
+	}
+	plans := dispatchPlan(planEventUpdatesAt(schedule, now), now, "2019-10-11", 0, loc)
+	if len(plans) != 1 || len(plans[0].Updates) != 1 || plans[0].Updates[0].Info.CurrentTitle != "OtherEvent1" || !plans[0].Updates[0].At.Equal(now) {
+		t.Errorf("Unexpected plan for the second day: %+v", plans)
+	}
+
+	now = time.Date(2019, 10, 10, 9, 0, 0, 0, loc)
+	plans = dispatchPlan(planEventUpdatesAt(schedule, now), now, "", 0, loc)
+	if len(plans) != 2 || plans[0].Room != "Room1" || len(plans[0].Updates) != 3 || len(plans[1].Updates) != 1 {
+		t.Fatalf("Unexpected plan: %+v", plans)
+	}
+	if at := plans[0].Updates[1].At; !at.Equal(time.Date(2019, 10, 10, 10, 30, 0, 0, loc)) {
+		t.Errorf("Event2 should be sent when Event1 ends. Got: %v", at)
+	}
+
+	var table bytes.Buffer
+	writeDispatchPlan(&table, plans)
+	lines := strings.Split(strings.TrimSpace(table.String()), "\n")
+	if len(lines) != 6 || !strings.HasPrefix(lines[1], "1 Room1  2019-10-10 09:00  10:00  Event1") || !strings.Contains(lines[1], "11:00 Event2") {
+		t.Errorf("Unexpected table:\n%v", table.String())
+	}
+
+	if plans = dispatchPlan(planEventUpdatesAt(schedule, now), now, "", 2, loc); len(plans) != 1 || plans[0].RoomID != 2 {
+		t.Errorf("Unexpected plan for room 2: %+v", plans)
+	}
+}
+
+func TestDryRunCommandKeepsTheCache(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(fetcherTestXML))
+	}))
+	defer server.Close()
+	defer func(fetchers []*ScheduleFetcher) { scheduleFetchers = fetchers }(scheduleFetchers)
+
+	cacheFile := filepath.Join(t.TempDir(), "schedule.xml")
+	ioutil.WriteFile(cacheFile, []byte("last good schedule"), 0644)
+	scheduleFetchers = []*ScheduleFetcher{NewScheduleFetcher(server.URL, cacheFile)}
+
+	if code := dryRunCommand([]string{"-format", "json"}); code != 0 {
+		t.Errorf("Unexpected exit code: %v", code)
+	}
+	if cached, _ := ioutil.ReadFile(cacheFile); string(cached) != "last good schedule" {
+		t.Errorf("A dry run should not change the cache file. Got: %v", string(cached))
+	}
+	if _, err := os.Stat(cacheFile + ".format"); !os.IsNotExist(err) {
+		t.Errorf("A dry run should not write the cache format. Got: %v", err)
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"text/template"
+	"time"
+)
+
+// RoomInfo.Phase after the events
+const (
+	phaseEnded  = "ended"  // no more events in the room today
+	phaseClosed = "closed" // the conference ended
+)
+
+// the room end messages. Empty disables them
+var endOfDayMessage = parseOptionalTemplateEnv("END_OF_DAY_MESSAGE")
+var closingMessage = parseOptionalTemplateEnv("CLOSING_MESSAGE")
+
+// EndOfDayData is what the END_OF_DAY_MESSAGE and CLOSING_MESSAGE templates see.
+// The next event is the first one of the room on a later day, if any.
+type EndOfDayData struct {
+	Room    string
+	Title   string // of the next event
+	Speaker string
+	Time    string // HH:MM, in the venue timezone
+	Date    string // 2006-01-02
+	Weekday string // e.g. Friday
+}
+
+// parseOptionalTemplateEnv is parseTemplateEnv, but unset means no template
+func parseOptionalTemplateEnv(key string) *template.Template {
+	if GetEnv(key, "") == "" {
+		return nil
+	}
+	return parseTemplateEnv(key, "")
+}
+
+// dayStarts returns when each day (2006-01-02) starts: its Day.Start, or midnight
+func dayStarts(schedule Schedule, loc *time.Location) map[string]time.Time {
+	starts := make(map[string]time.Time)
+	for _, day := range schedule.Days {
+		if start, err := ParseEventTime(day.Start, loc); err == nil {
+			starts[eventDay(start, loc)] = start
+		} else if date, err := time.ParseInLocation("2006-01-02", day.Date, loc); err == nil {
+			starts[day.Date] = date
+		}
+	}
+	return starts
+}
+
+// scheduleEnd returns the end of the last event
+func scheduleEnd(schedule Schedule, loc *time.Location) time.Time {
+	var last time.Time
+	for _, day := range schedule.Days {
+		for _, room := range day.Rooms {
+			for _, event := range room.Events {
+				start, err := ParseEventTime(event.Date, loc)
+				if err != nil {
+					continue
+				}
+				duration, _ := ParseCustomDuration(event.Duration)
+				if end := start.Add(duration); end.After(last) {
+					last = end
+				}
+			}
+		}
+	}
+	return last
+}
+
+// planRoomEnd returns the updates sent after event, if it is the last one of the day in the
+// room (the next one is on a later day, or there is none): END_OF_DAY_MESSAGE when it ends,
+// and CLOSING_MESSAGE when the conference ends, after the last event of the room.
+// shown is the RoomInfo of event, whose room name and auto loop (maybe from templates) are kept.
+func planRoomEnd(room Room, event, nextEvent Event, shown RoomInfo, conferenceEnd time.Time, loc *time.Location) []Job {
+	start, err := ParseEventTime(event.Date, loc)
+	if err != nil {
+		return nil
+	}
+	duration, _ := ParseCustomDuration(event.Duration)
+	end := start.Add(duration)
+
+	data := EndOfDayData{Room: shown.RoomName}
+	var nextStart time.Time
+	if nextEvent.Date != "" {
+		if nextStart, err = ParseEventTime(nextEvent.Date, loc); err != nil || eventDay(nextStart, loc) == eventDay(start, loc) {
+			return nil
+		}
+		next := createRoomInfo(room, nextEvent, Event{}, loc)
+		data.Title, data.Speaker, data.Time = next.CurrentTitle, next.CurrentSpeaker, next.CurrentTime
+		data.Date, data.Weekday = eventDay(nextStart, loc), nextStart.In(loc).Weekday().String()
+	}
+
+	endJob := func(suffix, phase string, at time.Time, message *template.Template) Job {
+		notice := renderNotice(message, data)
+		info := RoomInfo{
+			ID:           room.ID,
+			RoomName:     shown.RoomName,
+			CurrentTitle: notice,
+			NextTitle:    data.Title,
+			NextSpeaker:  data.Speaker,
+			NextTime:     data.Time,
+			AutoLoopSec:  shown.AutoLoopSec,
+			Phase:        phase,
+			Notice:       notice,
+		}
+		if !nextStart.IsZero() {
+			info.StartsAt = nextStart.Format(time.RFC3339)
+		}
+		return Job{Key: jobKey(room.ID, event) + suffix, RoomID: room.ID, At: at, Start: start, Info: info}
+	}
+
+	var jobs []Job
+	if endOfDayMessage != nil && (nextEvent.Date != "" || closingMessage == nil || end.Before(conferenceEnd)) {
+		jobs = append(jobs, endJob("/end-of-day", phaseEnded, end, endOfDayMessage))
+	}
+	if closingMessage != nil && nextEvent.Date == "" {
+		jobs = append(jobs, endJob("/closing", phaseClosed, conferenceEnd, closingMessage))
+	}
+	return jobs
+}
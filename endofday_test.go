@@ -0,0 +1,65 @@
+package main
+
+import (
+	"testing"
+	"text/template"
+	"time"
+)
+
+func TestPlanRoomEnd(t *testing.T) {
+	schedule := Schedule{Days: []Day{
+		{Date: "2019-10-10", Start: "2019-10-10T08:00:00Z", Rooms: []Room{
+			{ID: 1, Name: "Room1", Events: []Event{
+				{GUID: "abc1", Title: "Event1", Date: "2019-10-10T10:00:00Z", Duration: "00:30"},
+				{GUID: "abc2", Title: "Event2", Date: "2019-10-10T10:30:00Z", Duration: "00:30"},
+			}},
+			{ID: 2, Name: "Room2", Events: []Event{
+				{GUID: "cde1", Title: "OtherEvent1", Date: "2019-10-10T10:00:00Z", Duration: "00:30"},
+			}},
+		}},
+		{Date: "2019-10-11", Start: "2019-10-11T08:00:00Z", Rooms: []Room{
+			{ID: 1, Name: "Room1", Events: []Event{
+				{GUID: "efg1", Title: "Event3", Date: "2019-10-11T10:00:00Z", Duration: "01:00", Persons: []Person{{Name: "PersonName3"}}},
+			}},
+		}},
+	}}
+	defer func(endOfDay, closing *template.Template) {
+		endOfDayMessage, closingMessage = endOfDay, closing
+	}(endOfDayMessage, closingMessage)
+	endOfDayMessage = template.Must(template.New("end").Parse("Sessions in {{.Room}} have ended{{if .Title}} - see you {{.Weekday}} at {{.Time}} for {{.Title}}{{end}}"))
+	closingMessage = template.Must(template.New("closing").Parse("Thank you!"))
+
+	now := time.Date(2019, 10, 10, 9, 0, 0, 0, time.UTC)
+	expected := []struct {
+		key, at, phase, title string
+	}{
+		{"1/abc1", "2019-10-10 09:00", "", "Event1"},
+		{"1/abc2", "2019-10-10 10:30", "", "Event2"},
+		{"1/abc2/end-of-day", "2019-10-10 11:00", phaseEnded, "Sessions in Room1 have ended - see you Friday at 10:00 for Event3"},
+		{"1/efg1", "2019-10-11 08:00", "", "Event3"},
+		{"1/efg1/closing", "2019-10-11 11:00", phaseClosed, "Thank you!"},
+		{"2/cde1", "2019-10-10 09:00", "", "OtherEvent1"},
+		{"2/cde1/end-of-day", "2019-10-10 10:30", phaseEnded, "Sessions in Room2 have ended"},
+		{"2/cde1/closing", "2019-10-11 11:00", phaseClosed, "Thank you!"},
+	}
+	jobs := planEventUpdatesAt(schedule, now)
+	if len(jobs) != len(expected) {
+		t.Fatalf("Unexpected jobs: %+v", jobs)
+	}
+	for i, e := range expected {
+		job := jobs[i]
+		if job.Key != e.key || job.At.Format("2006-01-02 15:04") != e.at || job.Info.Phase != e.phase || job.Info.CurrentTitle != e.title {
+			t.Errorf("Unexpected job %v. Got: %v %v %q %q", i, job.Key, job.At.Format("2006-01-02 15:04"), job.Info.Phase, job.Info.CurrentTitle)
+		}
+	}
+	if info := jobs[2].Info; info.NextTitle != "Event3" || info.NextSpeaker != "PersonName3" || info.StartsAt != "2019-10-11T10:00:00Z" {
+		t.Errorf("The end of the day should show the next event. Got: %+v", info)
+	}
+
+	// restarting at night shows the end of the day
+	now = time.Date(2019, 10, 10, 22, 0, 0, 0, time.UTC)
+	jobs = catchUpUpdates(planEventUpdatesAt(schedule, now), now)
+	if len(jobs) != 5 || jobs[0].Key != "1/abc2/end-of-day" || jobs[3].Key != "2/cde1/end-of-day" || !jobs[3].At.Equal(now) {
+		t.Errorf("Unexpected catch-up: %+v", jobs)
+	}
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+)
+
+// ScheduleFetcher gets a schedule over HTTP using conditional requests
+// (ETag/Last-Modified), and keeps the last good one in a local cache file
+type ScheduleFetcher struct {
+	URL       string
+	CacheFile string // "" disables the on-disk cache
+	ReadOnly  bool   // falls back to the cache file, but never writes it
+	Client    *http.Client
+	Fallback  error // why the last Fetch read the cache file, nil if it did not
+
+	etag         string
+	lastModified string
+	lastBody     []byte
+	lastFormat   string
+}
+
+// NewScheduleFetcher creates a ScheduleFetcher for URL, caching into cacheFile
+func NewScheduleFetcher(URL, cacheFile string) *ScheduleFetcher {
+	return &ScheduleFetcher{URL: URL, CacheFile: cacheFile, Client: http.DefaultClient}
+}
+
+// Fetch gets the schedule from the URL. When the URL fails (transport error,
+// unexpected status or invalid body), it falls back to the cache file.
+// Local files (a path, or a file:// URL) are read directly, without cache.
+func (f *ScheduleFetcher) Fetch() (Schedule, error) {
+	if path, ok := localSchedulePath(f.URL); ok {
+		body, err := ioutil.ReadFile(path)
+		if err != nil {
+			return Schedule{}, err
+		}
+		return parseScheduleBody(body, detectScheduleFormat(body, path))
+	}
+
+	f.Fallback = nil
+	schedule, err := f.fetchRemote()
+	if err == nil {
+		return schedule, nil
+	}
+
+	if f.CacheFile == "" {
+		return Schedule{}, err
+	}
+	f.Fallback = err
+	log.Printf("WARNING: Could not read remote URL (%v). Fallbacking to local file %v\n", err, f.CacheFile)
+	body, err := ioutil.ReadFile(f.CacheFile)
+	if err != nil {
+		log.Println("Error reading file. Does it exist?")
+		return Schedule{}, err
+	}
+	return parseScheduleBody(body, f.cachedFormat(body))
+}
+
+// cacheFormatFile keeps the format of the cache file, as detected when it was fetched
+// (its name may not match, e.g. schedule.xml with a JSON schedule)
+func (f *ScheduleFetcher) cacheFormatFile() string {
+	return f.CacheFile + ".format"
+}
+
+// cachedFormat returns the format of the cache file body. Without a format file
+// (e.g. a cache file written by hand), it is detected from the URL, name and body.
+func (f *ScheduleFetcher) cachedFormat(body []byte) string {
+	if format, err := ioutil.ReadFile(f.cacheFormatFile()); err == nil {
+		if _, ok := scheduleParsers[string(format)]; ok {
+			return string(format)
+		}
+	}
+	return detectScheduleFormat(body, f.URL, f.CacheFile)
+}
+
+// localSchedulePath returns the file path of a file:// URL, or of a URL without scheme
+func localSchedulePath(URL string) (string, bool) {
+	u, err := url.Parse(URL)
+	switch {
+	case URL == "" || err != nil:
+		return "", false
+	case u.Scheme == "file":
+		return u.Path, true
+	case u.Scheme == "":
+		return URL, true
+	}
+	return "", false
+}
+
+func (f *ScheduleFetcher) fetchRemote() (Schedule, error) {
+	req, err := http.NewRequest(http.MethodGet, f.URL, nil)
+	if err != nil {
+		return Schedule{}, err
+	}
+	if f.lastBody != nil {
+		if f.etag != "" {
+			req.Header.Set("If-None-Match", f.etag)
+		}
+		if f.lastModified != "" {
+			req.Header.Set("If-Modified-Since", f.lastModified)
+		}
+	}
+
+	resp, err := f.Client.Do(req)
+	if err != nil {
+		return Schedule{}, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		if f.lastBody == nil {
+			return Schedule{}, fmt.Errorf("error: got %v without a previous schedule", resp.Status)
+		}
+		return parseScheduleBody(f.lastBody, f.lastFormat)
+	case http.StatusOK:
+	default:
+		return Schedule{}, fmt.Errorf("error: unexpected status %v", resp.Status)
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return Schedule{}, err
+	}
+	format := detectScheduleFormat(body, resp.Header.Get("Content-Type"), f.URL)
+	schedule, err := parseScheduleBody(body, format)
+	if err != nil {
+		return Schedule{}, err
+	}
+
+	f.etag = resp.Header.Get("ETag")
+	f.lastModified = resp.Header.Get("Last-Modified")
+	f.lastBody = body
+	f.lastFormat = format
+
+	if f.CacheFile != "" && !f.ReadOnly {
+		if err := writeFileAtomic(f.CacheFile, body); err != nil {
+			log.Println("WARNING: Could not update the local schedule file.", err)
+		} else if err := writeFileAtomic(f.cacheFormatFile(), []byte(format)); err != nil {
+			log.Println("WARNING: Could not save the format of the local schedule file.", err)
+			os.Remove(f.cacheFormatFile())
+		}
+	}
+	return schedule, nil
+}
+
+// writeFileAtomic writes into a temporary file and renames it, so readers
+// never see a partially written file
+func writeFileAtomic(filename string, data []byte) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(filename), filepath.Base(filename)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // no-op after a successful rename
+
+	if err := tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filename)
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+const fetcherTestXML = `<schedule>
+    <conference><title>Conference Title</title></conference>
+    <day index='1' date='2019-10-10'>
+        <room name='Room1'><event guid='abc1' id='1'><title>Event1</title></event></room>
+    </day>
+</schedule>`
+
+func TestScheduleFetcher(t *testing.T) {
+	var status int
+	var body string
+	var gotIfNoneMatch string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotIfNoneMatch = r.Header.Get("If-None-Match")
+		if gotIfNoneMatch == `"v1"` && status == http.StatusOK {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	cacheFile := filepath.Join(t.TempDir(), "schedule.xml")
+	fetcher := NewScheduleFetcher(server.URL, cacheFile)
+
+	// good response is cached on disk
+	status, body = http.StatusOK, fetcherTestXML
+	schedule, err := fetcher.Fetch()
+	if err != nil || schedule.Conference.Title != "Conference Title" {
+		t.Fatalf("Unexpected fetch result: %v, %v", schedule.Conference.Title, err)
+	}
+	if cached, _ := ioutil.ReadFile(cacheFile); string(cached) != fetcherTestXML {
+		t.Errorf("Cache file was not written. Got: %v", string(cached))
+	}
+
+	// second request is conditional
+	if schedule, err = fetcher.Fetch(); err != nil || gotIfNoneMatch != `"v1"` || len(schedule.Days) != 1 {
+		t.Errorf("Expected a conditional request (If-None-Match=%v): %v, %v", gotIfNoneMatch, schedule, err)
+	}
+
+	// server errors and truncated bodies fall back to the cache file
+	for _, tc := range []struct {
+		status int
+		body   string
+	}{
+		{http.StatusInternalServerError, "Internal Server Error"},
+		{http.StatusOK, fetcherTestXML[:60]},
+	} {
+		status, body = tc.status, tc.body
+		schedule, err = fetcher.Fetch()
+		if err != nil || schedule.Conference.Title != "Conference Title" {
+			t.Errorf("Expected fallback to cache file (%v): %v, %v", tc.status, schedule, err)
+		}
+		if cached, _ := ioutil.ReadFile(cacheFile); string(cached) != fetcherTestXML {
+			t.Errorf("Cache file should not be overwritten by a bad response. Got: %v", string(cached))
+		}
+	}
+}
+
+func TestScheduleFetcherWithoutCache(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	if _, err := NewScheduleFetcher(server.URL, "").Fetch(); err == nil {
+		t.Error("An error was expected")
+	}
+}
+
+func TestScheduleFetcherLocalFile(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "workshops.csv")
+	csv := "date,start,duration,room,title,speakers\n2019-10-10,10:00,45,Workshop Room,Event1,PersonName1\n"
+	if err := ioutil.WriteFile(filename, []byte(csv), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, URL := range []string{filename, "file://" + filename} {
+		schedule, err := NewScheduleFetcher(URL, "").Fetch()
+		if err != nil || len(schedule.Days) != 1 || schedule.Days[0].Rooms[0].Events[0].Title != "Event1" {
+			t.Errorf("Unexpected schedule from %v: %+v, %v", URL, schedule, err)
+		}
+	}
+}
+
+func TestScheduleFetcherFallbackFormat(t *testing.T) {
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write([]byte(scheduleTestJSON))
+	}))
+	defer server.Close()
+
+	// the URL has no extension, and the cache file name says xml
+	fetcher := NewScheduleFetcher(server.URL+"/schedule", filepath.Join(t.TempDir(), "schedule.xml"))
+	if _, err := fetcher.Fetch(); err != nil {
+		t.Fatal(err)
+	}
+
+	status = http.StatusInternalServerError
+	schedule, err := NewScheduleFetcher(server.URL+"/schedule", fetcher.CacheFile).Fetch()
+	if err != nil || schedule.Conference.Title != "Conference Title" {
+		t.Errorf("The cache file should be read as JSON: %+v, %v", schedule.Conference, err)
+	}
+}
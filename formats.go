@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"mime"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// scheduleParsers parse a schedule body, by format (see detectScheduleFormat)
+var scheduleParsers = map[string]func(body []byte) (Schedule, error){
+	"xml":  parseXMLSchedule,
+	"json": parseJSONSchedule,
+	"ics":  parseICSSchedule,
+	"csv":  parseCSVSchedule,
+}
+
+// formatsByExtension maps file extensions to schedule formats
+var formatsByExtension = map[string]string{
+	".xml":  "xml",
+	".json": "json",
+	".ics":  "ics",
+	".ical": "ics",
+	".csv":  "csv",
+}
+
+// formatsByMediaType maps Content-Types to schedule formats.
+// Media types with a +xml or +json suffix are also detected.
+var formatsByMediaType = map[string]string{
+	"application/xml":  "xml",
+	"text/xml":         "xml",
+	"application/json": "json",
+	"text/json":        "json",
+	"text/calendar":    "ics",
+	"text/csv":         "csv",
+}
+
+// detectScheduleFormat detects the format from the first conclusive hint (a Content-Type,
+// or a file name/URL with a known extension). Without one, it looks at the body.
+func detectScheduleFormat(body []byte, hints ...string) string {
+	for _, hint := range hints {
+		if format := formatFromMediaType(hint); format != "" {
+			return format
+		}
+		if format := formatFromName(hint); format != "" {
+			return format
+		}
+	}
+
+	switch trimmed := bytes.TrimSpace(body); {
+	case bytes.HasPrefix(trimmed, []byte("{")), bytes.HasPrefix(trimmed, []byte("[")):
+		return "json"
+	case bytes.HasPrefix(bytes.ToUpper(trimmed), []byte("BEGIN:VCALENDAR")):
+		return "ics"
+	}
+	return "xml"
+}
+
+func formatFromMediaType(contentType string) string {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil || !strings.Contains(mediaType, "/") {
+		return ""
+	}
+	if format, ok := formatsByMediaType[mediaType]; ok {
+		return format
+	}
+	for suffix, format := range map[string]string{"+xml": "xml", "+json": "json"} {
+		if strings.HasSuffix(mediaType, suffix) {
+			return format
+		}
+	}
+	return ""
+}
+
+func formatFromName(name string) string {
+	if u, err := url.Parse(name); err == nil && u.Path != "" {
+		name = u.Path
+	}
+	return formatsByExtension[strings.ToLower(path.Ext(name))]
+}
+
+// parseScheduleBody validates a schedule body by parsing it
+func parseScheduleBody(body []byte, format string) (Schedule, error) {
+	parse, ok := scheduleParsers[format]
+	if !ok {
+		return Schedule{}, fmt.Errorf("error: unknown schedule format %q", format)
+	}
+	schedule, err := parse(body)
+	if err != nil {
+		return Schedule{}, fmt.Errorf("error parsing %v schedule: %v", format, err)
+	}
+	if len(schedule.Days) == 0 {
+		return Schedule{}, fmt.Errorf("error parsing %v schedule: no days found", format)
+	}
+	return schedule, nil
+}
+
+func parseXMLSchedule(body []byte) (Schedule, error) {
+	schedule := Schedule{}
+	err := xml.Unmarshal(body, &schedule)
+	return schedule, err
+}
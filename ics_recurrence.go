@@ -0,0 +1,481 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	icsMaxOccurrences = 10000 // of a single recurring event
+	icsMaxPeriods     = 10000 // days/weeks/months/years looked at by a RRULE
+)
+
+// icsZone converts the wall clock time of a TZID (held in UTC) into an instant
+type icsZone func(wall time.Time) time.Time
+
+func utcICSZone(wall time.Time) time.Time { return wall }
+
+func locationICSZone(loc *time.Location) icsZone {
+	return func(wall time.Time) time.Time {
+		return time.Date(wall.Year(), wall.Month(), wall.Day(), wall.Hour(), wall.Minute(), wall.Second(), 0, loc)
+	}
+}
+
+// icsZones resolves the TZIDs of a calendar: IANA names (e.g. Europe/Lisbon) are loaded,
+// others are built from the calendar VTIMEZONE. Times without TZID are in the floating location.
+type icsZones struct {
+	floating *time.Location
+	defined  map[string]*icsComponent
+	resolved map[string]icsZone
+}
+
+func newICSZones(calendar *icsComponent, floating *time.Location) *icsZones {
+	zones := &icsZones{
+		floating: floating,
+		defined:  make(map[string]*icsComponent),
+		resolved: map[string]icsZone{"": locationICSZone(floating)},
+	}
+	for _, c := range calendar.Children {
+		if c.Name == "VTIMEZONE" {
+			zones.defined[c.Text("TZID")] = c
+		}
+	}
+	return zones
+}
+
+func (z *icsZones) zone(tzid string) (icsZone, error) {
+	if zone, ok := z.resolved[tzid]; ok {
+		return zone, nil
+	}
+
+	var zone icsZone
+	if loc, err := time.LoadLocation(tzid); err == nil && tzid != "Local" {
+		zone = locationICSZone(loc)
+	} else if tz, ok := z.defined[tzid]; ok {
+		if zone, err = z.vtimezone(tzid, tz); err != nil {
+			return nil, err
+		}
+	} else {
+		return nil, fmt.Errorf("error: unknown TZID %q", tzid)
+	}
+	z.resolved[tzid] = zone
+	return zone, nil
+}
+
+// wall parses a DATE-TIME (or DATE) property into its wall clock time and zone
+func (z *icsZones) wall(p icsProperty) (time.Time, icsZone, error) {
+	if isICSDate(p) {
+		wall, err := time.Parse("20060102", p.Value)
+		return wall, z.resolved[""], err
+	}
+	if strings.HasSuffix(p.Value, "Z") {
+		wall, err := time.Parse("20060102T150405Z", p.Value)
+		return wall, utcICSZone, err
+	}
+
+	wall, err := time.Parse("20060102T150405", p.Value)
+	if err != nil {
+		return time.Time{}, nil, err
+	}
+	zone, err := z.zone(p.Params["TZID"])
+	return wall, zone, err
+}
+
+// parse returns the instant of a DATE-TIME (or DATE, at its start) property
+func (z *icsZones) parse(p icsProperty) (time.Time, icsZone, error) {
+	wall, zone, err := z.wall(p)
+	if err != nil {
+		return time.Time{}, nil, err
+	}
+	return zone(wall), zone, nil
+}
+
+// icsObservance is a STANDARD or DAYLIGHT period of a VTIMEZONE
+type icsObservance struct {
+	start  time.Time // wall clock onset
+	from   int       // UTC offset before the onset, in seconds
+	to     int       // UTC offset from the onset on
+	rule   *icsRecurrence
+	rdates []time.Time
+}
+
+// vtimezone builds the zone from the observances: a wall clock time has the offset
+// of the observance with the latest onset before it
+func (z *icsZones) vtimezone(tzid string, tz *icsComponent) (icsZone, error) {
+	var observances []icsObservance
+	for _, c := range tz.Children {
+		if c.Name != "STANDARD" && c.Name != "DAYLIGHT" {
+			continue
+		}
+		dtstart, _ := c.Get("DTSTART")
+		start, err := time.Parse("20060102T150405", dtstart.Value)
+		if err != nil {
+			return nil, fmt.Errorf("error: invalid VTIMEZONE %q: %v", tzid, err)
+		}
+		o := icsObservance{start: start}
+		if o.from, err = parseICSOffset(c.Text("TZOFFSETFROM")); err != nil {
+			return nil, err
+		}
+		if o.to, err = parseICSOffset(c.Text("TZOFFSETTO")); err != nil {
+			return nil, err
+		}
+		if rrule, ok := c.Get("RRULE"); ok {
+			rule, err := parseICSRecurrence(rrule.Value, nil, z)
+			if err != nil {
+				return nil, err
+			}
+			o.rule = &rule
+		}
+		for _, p := range c.All("RDATE") {
+			for _, value := range strings.Split(p.Value, ",") {
+				if rdate, err := time.Parse("20060102T150405", value); err == nil {
+					o.rdates = append(o.rdates, rdate)
+				}
+			}
+		}
+		observances = append(observances, o)
+	}
+	if len(observances) == 0 {
+		return nil, fmt.Errorf("error: VTIMEZONE %q has no STANDARD or DAYLIGHT", tzid)
+	}
+	sort.Slice(observances, func(i, j int) bool { return observances[i].start.Before(observances[j].start) })
+
+	return func(wall time.Time) time.Time {
+		offset, latest, found := observances[0].from, time.Time{}, false
+		for _, o := range observances {
+			if onset, ok := o.lastOnset(wall); ok && (!found || onset.After(latest)) {
+				offset, latest, found = o.to, onset, true
+			}
+		}
+		return time.Date(wall.Year(), wall.Month(), wall.Day(), wall.Hour(), wall.Minute(), wall.Second(), 0, time.FixedZone(tzid, offset))
+	}, nil
+}
+
+// lastOnset returns the latest onset of the observance not after the wall clock time
+func (o icsObservance) lastOnset(wall time.Time) (time.Time, bool) {
+	var last time.Time
+	found := false
+	consider := func(onset time.Time) {
+		if !onset.After(wall) && !onset.Before(o.start) && (!found || onset.After(last)) {
+			last, found = onset, true
+		}
+	}
+
+	consider(o.start)
+	for _, rdate := range o.rdates {
+		consider(rdate)
+	}
+	if o.rule == nil {
+		return last, found
+	}
+
+	var onsets []time.Time
+	if o.rule.Freq == "YEARLY" && o.rule.Interval == 1 {
+		for _, year := range []int{wall.Year() - 1, wall.Year()} {
+			onsets = append(onsets, o.rule.candidates(time.Date(year, 1, 1, 0, 0, 0, 0, time.UTC), o.start)...)
+		}
+	} else {
+		onsets = o.rule.Expand(o.start, utcICSZone, wall)
+	}
+	for _, onset := range onsets {
+		utc := onset.Add(-time.Duration(o.from) * time.Second)
+		if o.rule.Until.IsZero() || !utc.After(o.rule.Until) {
+			consider(onset)
+		}
+	}
+	return last, found
+}
+
+// parseICSOffset parses a UTC offset (+HHMM, -HHMM or +HHMMSS) into seconds
+func parseICSOffset(value string) (int, error) {
+	if (len(value) != 5 && len(value) != 7) || (value[0] != '+' && value[0] != '-') {
+		return 0, fmt.Errorf("error: invalid UTC offset %q", value)
+	}
+	digits, err := strconv.Atoi(value[1:])
+	if err != nil {
+		return 0, fmt.Errorf("error: invalid UTC offset %q", value)
+	}
+	if len(value) == 5 {
+		digits *= 100
+	}
+	seconds := digits/10000*3600 + digits/100%100*60 + digits%100
+	if value[0] == '-' {
+		seconds = -seconds
+	}
+	return seconds, nil
+}
+
+// icsWeekday is a BYDAY item, e.g. MO, 2SU (second sunday) or -1SU (last sunday)
+type icsWeekday struct {
+	N   int
+	Day time.Weekday
+}
+
+var icsWeekdays = map[string]time.Weekday{
+	"SU": time.Sunday, "MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday,
+	"TH": time.Thursday, "FR": time.Friday, "SA": time.Saturday,
+}
+
+// icsRecurrence is a RRULE. FREQ DAILY, WEEKLY, MONTHLY and YEARLY are supported,
+// with INTERVAL, COUNT, UNTIL, BYDAY, BYMONTHDAY, BYMONTH and WKST.
+type icsRecurrence struct {
+	Freq       string
+	Interval   int
+	Count      int
+	Until      time.Time // instant, zero for none
+	ByDay      []icsWeekday
+	ByMonthDay []int
+	ByMonth    []time.Month
+	WeekStart  time.Weekday
+}
+
+// parseICSRecurrence parses a RRULE. dtstartParams are the DTSTART parameters, used for a local UNTIL.
+func parseICSRecurrence(value string, dtstartParams map[string]string, zones *icsZones) (icsRecurrence, error) {
+	rule := icsRecurrence{Interval: 1, WeekStart: time.Monday}
+	invalid := func(part string) (icsRecurrence, error) {
+		return icsRecurrence{}, fmt.Errorf("error: invalid RRULE %q (%v)", value, part)
+	}
+
+	for _, part := range strings.Split(value, ";") {
+		eq := strings.Index(part, "=")
+		if eq < 0 {
+			return invalid(part)
+		}
+		key, val := strings.ToUpper(part[:eq]), strings.ToUpper(part[eq+1:])
+
+		var err error
+		switch key {
+		case "FREQ":
+			rule.Freq = val
+		case "INTERVAL":
+			if rule.Interval, err = strconv.Atoi(val); err != nil || rule.Interval < 1 {
+				return invalid(part)
+			}
+		case "COUNT":
+			if rule.Count, err = strconv.Atoi(val); err != nil || rule.Count < 1 {
+				return invalid(part)
+			}
+		case "UNTIL":
+			until := icsProperty{Name: "UNTIL", Params: dtstartParams, Value: val}
+			if rule.Until, _, err = zones.parse(until); err != nil {
+				return invalid(part)
+			}
+			if isICSDate(until) {
+				rule.Until = rule.Until.Add(24*time.Hour - time.Second)
+			}
+		case "BYDAY":
+			for _, day := range strings.Split(val, ",") {
+				if len(day) < 2 {
+					return invalid(part)
+				}
+				weekday, ok := icsWeekdays[day[len(day)-2:]]
+				n := 0
+				if prefix := day[:len(day)-2]; prefix != "" {
+					n, err = strconv.Atoi(strings.TrimPrefix(prefix, "+"))
+				}
+				if !ok || err != nil {
+					return invalid(part)
+				}
+				rule.ByDay = append(rule.ByDay, icsWeekday{N: n, Day: weekday})
+			}
+		case "BYMONTHDAY":
+			for _, day := range strings.Split(val, ",") {
+				n, err := strconv.Atoi(day)
+				if err != nil || n == 0 || n < -31 || n > 31 {
+					return invalid(part)
+				}
+				rule.ByMonthDay = append(rule.ByMonthDay, n)
+			}
+		case "BYMONTH":
+			for _, month := range strings.Split(val, ",") {
+				n, err := strconv.Atoi(month)
+				if err != nil || n < 1 || n > 12 {
+					return invalid(part)
+				}
+				rule.ByMonth = append(rule.ByMonth, time.Month(n))
+			}
+		case "WKST":
+			weekday, ok := icsWeekdays[val]
+			if !ok {
+				return invalid(part)
+			}
+			rule.WeekStart = weekday
+		}
+	}
+
+	switch rule.Freq {
+	case "DAILY", "WEEKLY", "MONTHLY", "YEARLY":
+		return rule, nil
+	default:
+		return icsRecurrence{}, fmt.Errorf("error: unsupported RRULE FREQ %q", rule.Freq)
+	}
+}
+
+// Expand returns the wall clock start of the occurrences (DTSTART being the first one).
+// Rules without COUNT or UNTIL stop at the horizon.
+func (r icsRecurrence) Expand(dtstart time.Time, zone icsZone, horizon time.Time) []time.Time {
+	occurrences := []time.Time{dtstart}
+	bounded := r.Count > 0 || !r.Until.IsZero()
+	done := func(t time.Time) bool {
+		return (!r.Until.IsZero() && t.After(r.Until)) || (!bounded && t.After(horizon))
+	}
+
+	first := r.periodStart(dtstart)
+	for p := 0; p < icsMaxPeriods; p++ {
+		period := r.advance(first, p*r.Interval)
+		if done(zone(period)) {
+			break
+		}
+		for _, wall := range r.candidates(period, dtstart) {
+			if !wall.After(dtstart) {
+				continue
+			}
+			if done(zone(wall)) || (r.Count > 0 && len(occurrences) >= r.Count) || len(occurrences) >= icsMaxOccurrences {
+				return occurrences
+			}
+			occurrences = append(occurrences, wall)
+		}
+	}
+	return occurrences
+}
+
+// periodStart is the first day of the DTSTART day/week/month/year
+func (r icsRecurrence) periodStart(dtstart time.Time) time.Time {
+	day := time.Date(dtstart.Year(), dtstart.Month(), dtstart.Day(), 0, 0, 0, 0, time.UTC)
+	switch r.Freq {
+	case "WEEKLY":
+		return day.AddDate(0, 0, -((int(day.Weekday()) - int(r.WeekStart) + 7) % 7))
+	case "MONTHLY":
+		return day.AddDate(0, 0, 1-day.Day())
+	case "YEARLY":
+		return time.Date(day.Year(), 1, 1, 0, 0, 0, 0, time.UTC)
+	}
+	return day
+}
+
+func (r icsRecurrence) advance(period time.Time, n int) time.Time {
+	switch r.Freq {
+	case "WEEKLY":
+		return period.AddDate(0, 0, 7*n)
+	case "MONTHLY":
+		return period.AddDate(0, n, 0)
+	case "YEARLY":
+		return period.AddDate(n, 0, 0)
+	}
+	return period.AddDate(0, 0, n)
+}
+
+// candidates returns the sorted wall clock times of the rule in the period (at the DTSTART time of day)
+func (r icsRecurrence) candidates(period, dtstart time.Time) []time.Time {
+	var days []time.Time
+	switch r.Freq {
+	case "DAILY":
+		days = []time.Time{period}
+	case "WEEKLY":
+		weekdays := []time.Weekday{dtstart.Weekday()}
+		if len(r.ByDay) > 0 {
+			weekdays = nil
+			for _, d := range r.ByDay {
+				weekdays = append(weekdays, d.Day)
+			}
+		}
+		for _, weekday := range weekdays {
+			days = append(days, period.AddDate(0, 0, (int(weekday)-int(r.WeekStart)+7)%7))
+		}
+	case "MONTHLY":
+		days = r.monthDays(period.Year(), period.Month(), dtstart)
+	case "YEARLY":
+		months := r.ByMonth
+		if len(months) == 0 {
+			months = []time.Month{dtstart.Month()}
+		}
+		for _, month := range months {
+			days = append(days, r.monthDays(period.Year(), month, dtstart)...)
+		}
+	}
+
+	var candidates []time.Time
+	seen := make(map[time.Time]bool)
+	for _, day := range days {
+		if !r.matches(day) || seen[day] {
+			continue
+		}
+		seen[day] = true
+		candidates = append(candidates, time.Date(day.Year(), day.Month(), day.Day(), dtstart.Hour(), dtstart.Minute(), dtstart.Second(), 0, time.UTC))
+	}
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].Before(candidates[j]) })
+	return candidates
+}
+
+// monthDays returns the days of the month given by BYMONTHDAY, BYDAY or the DTSTART day
+func (r icsRecurrence) monthDays(year int, month time.Month, dtstart time.Time) []time.Time {
+	n := daysInMonth(year, month)
+	date := func(day int) time.Time { return time.Date(year, month, day, 0, 0, 0, 0, time.UTC) }
+
+	var days []time.Time
+	switch {
+	case len(r.ByMonthDay) > 0:
+		for _, md := range r.ByMonthDay {
+			if md < 0 {
+				md = n + md + 1
+			}
+			if md >= 1 && md <= n {
+				days = append(days, date(md))
+			}
+		}
+	case len(r.ByDay) > 0:
+		for _, wd := range r.ByDay {
+			for day := 1; day <= n; day++ {
+				if date(day).Weekday() != wd.Day {
+					continue
+				}
+				nth, nthLast := (day-1)/7+1, -((n-day)/7 + 1)
+				if wd.N == 0 || wd.N == nth || wd.N == nthLast {
+					days = append(days, date(day))
+				}
+			}
+		}
+	default:
+		if dtstart.Day() <= n {
+			days = append(days, date(dtstart.Day()))
+		}
+	}
+	return days
+}
+
+// matches applies the BYMONTH, BYMONTHDAY and BYDAY (weekday) filters
+func (r icsRecurrence) matches(day time.Time) bool {
+	if len(r.ByMonth) > 0 {
+		found := false
+		for _, m := range r.ByMonth {
+			found = found || m == day.Month()
+		}
+		if !found {
+			return false
+		}
+	}
+	if len(r.ByMonthDay) > 0 {
+		n, found := daysInMonth(day.Year(), day.Month()), false
+		for _, md := range r.ByMonthDay {
+			found = found || md == day.Day() || n+md+1 == day.Day()
+		}
+		if !found {
+			return false
+		}
+	}
+	if len(r.ByDay) > 0 {
+		found := false
+		for _, wd := range r.ByDay {
+			found = found || wd.Day == day.Weekday()
+		}
+		return found
+	}
+	return true
+}
+
+func daysInMonth(year int, month time.Month) int {
+	return time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
@@ -86,7 +86,7 @@ func TestCreateRoomInfoJSONBody(t *testing.T) {
 		},
 	}
 
-	roomInfoJSON := createRoomInfoJSONBody(room, event, nextEvent)
+	roomInfoJSON := createRoomInfoJSONBody(room, event, nextEvent, time.UTC)
 	expectedJSON := `{"room_id":1,"room":"RoomName","title":"EventTitle","speaker":"PersonName1, PersonName2","time":"10:00","n_title":"EventTitle2","n_speaker":"PersonName2","n_time":"11:00","auto_loop_sec":5}`
 
 	if string(roomInfoJSON) != expectedJSON {
@@ -264,3 +264,79 @@ func TestParseCustomDuration(t *testing.T) {
 		t.Errorf("Error was expected (01:xx: %v). '%v'", x, err)
 	}
 }
+
+func TestCatchUpUpdates(t *testing.T) {
+	schedule := Schedule{
+		Days: []Day{
+			{Date: "2019-10-10", Rooms: []Room{
+				{ID: 1, Name: "Room1", Events: []Event{
+					{GUID: "abc1", Title: "Event1", Date: "2019-10-10T09:00:00Z", Duration: "00:30"},
+					{GUID: "abc2", Title: "Event2", Date: "2019-10-10T09:30:00Z", Duration: "01:00"},
+					{GUID: "abc3", Title: "Event3", Date: "2019-10-10T10:30:00Z", Duration: "00:30"},
+				}},
+				{ID: 2, Name: "Room2", Events: []Event{
+					{GUID: "cde1", Title: "OtherEvent1", Date: "2019-10-10T08:00:00Z", Duration: "00:30"},
+					{GUID: "cde2", Title: "OtherEvent2", Date: "2019-10-10T08:30:00Z", Duration: "00:30"},
+					{GUID: "cde3", Title: "OtherEvent3", Date: "2019-10-10T10:30:00Z", Duration: "00:30"},
+				}},
+				{ID: 3, Name: "Room3", Events: []Event{
+					{GUID: "efg1", Title: "LongEvent1", Date: "2019-10-10T09:00:00Z", Duration: "03:00"},
+					{GUID: "efg2", Title: "ShortEvent2", Date: "2019-10-10T09:15:00Z", Duration: "00:15"},
+				}},
+			}},
+		},
+	}
+
+	now := time.Date(2019, 10, 10, 10, 0, 0, 0, time.UTC)
+	jobs := catchUpUpdates(planEventUpdatesAt(schedule, now), now)
+
+	due := make(map[int]Job)
+	for _, job := range jobs {
+		if job.At.After(now) {
+			continue
+		}
+		if _, ok := due[job.RoomID]; ok {
+			t.Errorf("Room %v should get a single catch-up update. Got another: %v", job.RoomID, job.Key)
+		}
+		if !job.At.Equal(now) {
+			t.Errorf("Catch-up updates should be sent now. Got: %v", job.At)
+		}
+		due[job.RoomID] = job
+	}
+	if job := due[1]; job.Info.CurrentTitle != "Event2" || job.Info.NextTitle != "Event3" {
+		t.Errorf("Room1 should catch up with Event2, which is in progress. Got: %+v", job.Info)
+	}
+	if job := due[2]; job.Info.CurrentTitle != "OtherEvent3" {
+		t.Errorf("Room2 should catch up with OtherEvent3, which is next. Got: %+v", job.Info)
+	}
+	if job := due[3]; job.Info.CurrentTitle != "LongEvent1" {
+		t.Errorf("Room3 should catch up with LongEvent1, which started first. Got: %+v", job.Info)
+	}
+	if len(jobs) != 4 || jobs[1].Key != "1/abc3" || !jobs[1].At.Equal(time.Date(2019, 10, 10, 10, 30, 0, 0, time.UTC)) {
+		t.Errorf("The future updates should be kept. Got: %+v", jobs)
+	}
+}
+
+func TestPlanNextEventAcrossDays(t *testing.T) {
+	schedule := Schedule{Days: []Day{
+		{Date: "2019-10-10", Rooms: []Room{
+			{ID: 1, Name: "Room1", Events: []Event{{GUID: "abc1", Title: "Event1", Date: "2019-10-10T10:00:00Z", Duration: "00:30"}}},
+			{ID: 2, Name: "Room2", SameDayNext: true, Events: []Event{{GUID: "cde1", Title: "OtherEvent1", Date: "2019-10-10T10:00:00Z", Duration: "00:30"}}},
+		}},
+		{Date: "2019-10-11", Rooms: []Room{
+			{ID: 1, Name: "Room1", Events: []Event{{GUID: "abc2", Title: "Event2", Date: "2019-10-11T10:00:00Z", Duration: "00:30"}}},
+			{ID: 2, Name: "Room2", SameDayNext: true, Events: []Event{{GUID: "cde2", Title: "OtherEvent2", Date: "2019-10-11T10:00:00Z", Duration: "00:30"}}},
+		}},
+	}}
+
+	jobs := planEventUpdatesAt(schedule, time.Date(2019, 10, 10, 9, 0, 0, 0, time.UTC))
+	if len(jobs) != 4 {
+		t.Fatalf("Unexpected jobs: %+v", jobs)
+	}
+	if info := jobs[0].Info; info.NextTitle != "Event2" || info.NextTime != "Fri 10:00" {
+		t.Errorf("The next event on another day should show its weekday. Got: %+v", info)
+	}
+	if info := jobs[2].Info; info.NextTitle != "" || info.NextTime != "" {
+		t.Errorf("Room2 should not show the next event on another day. Got: %+v", info)
+	}
+}
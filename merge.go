@@ -0,0 +1,238 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var schedulePriority = GetEnv("SCHEDULE_PRIORITY", "") // e.g. "2,1": the second SCHEDULE_URL source wins conflicts
+
+// ScheduleSource is a schedule to merge. On conflicts, the lowest Priority wins.
+type ScheduleSource struct {
+	Name     string
+	Priority int
+	Schedule Schedule
+}
+
+// MergeConflict is a pair of events of the same room that are duplicates (same GUID,
+// or same start) or overlap. The Other event is dropped, unless both come from the same source.
+type MergeConflict struct {
+	Kind      string `json:"kind"` // "duplicate" or "overlap"
+	Day       string `json:"day"`
+	Room      string `json:"room"`
+	Kept      Event  `json:"kept"`
+	KeptFrom  string `json:"kept_from"`
+	Other     Event  `json:"other"`
+	OtherFrom string `json:"other_from"`
+	Dropped   bool   `json:"dropped"`
+}
+
+func (c MergeConflict) String() string {
+	resolution := "kept both"
+	if c.Dropped {
+		resolution = "dropped the second"
+	}
+	return fmt.Sprintf("%v on %v in %v: %q at %v (%v) and %q at %v (%v), %v",
+		c.Kind, c.Day, c.Room, c.Kept.Title, c.Kept.Date, c.KeptFrom, c.Other.Title, c.Other.Date, c.OtherFrom, resolution)
+}
+
+// ParseSchedulePriority parses SCHEDULE_PRIORITY: the source numbers (1 is the first SCHEDULE_URL),
+// from the highest priority. It returns the priority of each source; unlisted ones come last, in order.
+func ParseSchedulePriority(config string, sources int) ([]int, error) {
+	priorities := make([]int, sources)
+	for i := range priorities {
+		priorities[i] = -1
+	}
+
+	next := 0
+	for _, item := range strings.Split(config, ",") {
+		if strings.TrimSpace(item) == "" {
+			continue
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(item))
+		if err != nil || n < 1 || n > sources || priorities[n-1] >= 0 {
+			return nil, fmt.Errorf("error: invalid SCHEDULE_PRIORITY %q. Expected source numbers from 1 to %v, without repeats", config, sources)
+		}
+		priorities[n-1] = next
+		next++
+	}
+	for i := range priorities {
+		if priorities[i] < 0 {
+			priorities[i] = next
+			next++
+		}
+	}
+	return priorities, nil
+}
+
+// mergedEvent is an event being merged, with its source and time range (when its date is valid)
+type mergedEvent struct {
+	event      Event
+	source     int
+	start, end time.Time
+	timed      bool
+}
+
+type mergedRoom struct {
+	name   string
+	events []mergedEvent
+}
+
+type mergedDay struct {
+	day   Day
+	rooms []*mergedRoom
+}
+
+// MergeSchedules merges the sources into the conference of the first one (taking the
+// timezone of another source if it has none). Days and rooms
+// missing from it are added (rooms match by name, ignoring case), and the events of each
+// room are sorted by start. Conflicts are resolved by priority, and all of them are returned.
+func MergeSchedules(sources []ScheduleSource) (Schedule, []MergeConflict) {
+	if len(sources) == 0 {
+		return Schedule{}, nil
+	}
+
+	schedule := Schedule{Version: sources[0].Schedule.Version, Conference: sources[0].Schedule.Conference}
+	for _, source := range sources {
+		if schedule.Conference.TimeZoneName == "" {
+			schedule.Conference.TimeZoneName = source.Schedule.Conference.TimeZoneName
+		}
+	}
+	loc := venueLocation(schedule.Conference)
+
+	// days and rooms keep the order of the sources, starting with the main one
+	var days []*mergedDay
+	daysByDate := make(map[string]*mergedDay)
+	roomOf := func(day *mergedDay, name string) *mergedRoom {
+		for _, room := range day.rooms {
+			if strings.EqualFold(strings.TrimSpace(room.name), strings.TrimSpace(name)) {
+				return room
+			}
+		}
+		room := &mergedRoom{name: name}
+		day.rooms = append(day.rooms, room)
+		return room
+	}
+	for _, source := range sources {
+		for _, day := range source.Schedule.Days {
+			if _, ok := daysByDate[day.Date]; !ok {
+				d := &mergedDay{day: Day{Date: day.Date, Start: day.Start, End: day.End}}
+				daysByDate[day.Date] = d
+				days = append(days, d)
+			}
+			for _, room := range day.Rooms {
+				roomOf(daysByDate[day.Date], room.Name)
+			}
+		}
+	}
+
+	// events are added from the highest priority source, so a conflict drops the incoming event
+	order := make([]int, len(sources))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool { return sources[order[i]].Priority < sources[order[j]].Priority })
+
+	var conflicts []MergeConflict
+	byGUID := make(map[string]mergedEvent)
+	for _, s := range order {
+		for _, day := range sources[s].Schedule.Days {
+			for _, room := range day.Rooms {
+				merged := roomOf(daysByDate[day.Date], room.Name)
+				for _, event := range room.Events {
+					incoming := newMergedEvent(event, s, loc)
+					if kind, kept, found := findMergeConflict(incoming, merged.events, byGUID); found {
+						conflicts = append(conflicts, MergeConflict{
+							Kind:      kind,
+							Day:       day.Date,
+							Room:      merged.name,
+							Kept:      kept.event,
+							KeptFrom:  sources[kept.source].Name,
+							Other:     event,
+							OtherFrom: sources[s].Name,
+							Dropped:   kept.source != s,
+						})
+						if kept.source != s {
+							continue
+						}
+					}
+					merged.events = append(merged.events, incoming)
+					if event.GUID != "" {
+						byGUID[event.GUID] = incoming
+					}
+				}
+			}
+		}
+	}
+
+	sort.SliceStable(days, func(i, j int) bool { return days[i].day.Date < days[j].day.Date })
+	for _, d := range days {
+		for _, room := range d.rooms {
+			sort.SliceStable(room.events, func(i, j int) bool {
+				a, b := room.events[i], room.events[j]
+				return a.timed && (!b.timed || a.start.Before(b.start))
+			})
+			merged := Room{Name: room.name}
+			for _, e := range room.events {
+				merged.Events = append(merged.Events, e.event)
+			}
+			d.day.Rooms = append(d.day.Rooms, merged)
+		}
+		schedule.Days = append(schedule.Days, d.day)
+	}
+	extendConferenceDates(&schedule)
+	return schedule, conflicts
+}
+
+func newMergedEvent(event Event, source int, loc *time.Location) mergedEvent {
+	merged := mergedEvent{event: event, source: source}
+	start, err := ParseEventTime(event.Date, loc)
+	if err != nil {
+		return merged // rejected later (see validEvents), only its GUID can conflict
+	}
+	duration, _ := ParseCustomDuration(event.Duration)
+	merged.start, merged.end, merged.timed = start, start.Add(duration), true
+	return merged
+}
+
+// findMergeConflict looks for an already merged event with the same GUID (in any room), or
+// with the same start or an overlapping time in the room
+func findMergeConflict(incoming mergedEvent, room []mergedEvent, byGUID map[string]mergedEvent) (kind string, kept mergedEvent, found bool) {
+	if kept, ok := byGUID[incoming.event.GUID]; ok && incoming.event.GUID != "" {
+		return "duplicate", kept, true
+	}
+	if !incoming.timed {
+		return "", mergedEvent{}, false
+	}
+	for _, kept := range room {
+		switch {
+		case !kept.timed:
+		case kept.start.Equal(incoming.start):
+			return "duplicate", kept, true
+		case kept.start.Before(incoming.end) && incoming.start.Before(kept.end):
+			return "overlap", kept, true
+		}
+	}
+	return "", mergedEvent{}, false
+}
+
+// extendConferenceDates makes the conference Start/End/Days cover the schedule days
+func extendConferenceDates(schedule *Schedule) {
+	n := len(schedule.Days)
+	if n == 0 {
+		return
+	}
+	c := &schedule.Conference
+	if first := schedule.Days[0].Date; c.Start == "" || first < c.Start {
+		c.Start = first
+	}
+	if last := schedule.Days[n-1].Date; c.End == "" || last > c.End {
+		c.End = last
+	}
+	if c.Days < n {
+		c.Days = n
+	}
+}
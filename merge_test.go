@@ -0,0 +1,125 @@
+package main
+
+import (
+	"testing"
+)
+
+func mergeTestEvent(guid, title, date, duration string) Event {
+	return Event{GUID: guid, Title: title, Date: date, Duration: duration}
+}
+
+func TestMergeSchedules(t *testing.T) {
+	mainSchedule := Schedule{
+		Conference: Conference{Title: "Conference Title", Start: "2019-10-10", End: "2019-10-10", Days: 1, TimeZoneName: "Europe/Lisbon"},
+		Days: []Day{{Date: "2019-10-10", Rooms: []Room{
+			{Name: "Room1", Events: []Event{
+				mergeTestEvent("abc2", "Event2", "2019-10-10T11:00:00+01:00", "00:30"),
+				mergeTestEvent("abc1", "Event1", "2019-10-10T10:00:00+01:00", "00:30"),
+			}},
+		}}},
+	}
+	extra := Schedule{
+		Days: []Day{
+			{Date: "2019-10-10", Rooms: []Room{
+				{Name: "room1 ", Events: []Event{
+					mergeTestEvent("", "Workshop", "2019-10-10T09:00:00", "00:30"),          // added before Event1
+					mergeTestEvent("abc1", "Event1 (copy)", "2019-10-10T12:00:00", "00:30"), // same GUID
+					mergeTestEvent("", "Same start", "2019-10-10T11:00:00", "00:15"),        // same time and room
+					mergeTestEvent("", "Overlap", "2019-10-10T10:15:00", "00:30"),           // overlaps Event1
+				}},
+				{Name: "Workshop Room", Events: []Event{
+					mergeTestEvent("", "Workshop2", "2019-10-10T10:00:00", "01:00"),
+				}},
+			}},
+			{Date: "2019-10-11", Rooms: []Room{
+				{Name: "Workshop Room", Events: []Event{
+					mergeTestEvent("", "Workshop3", "2019-10-11T10:00:00", "01:00"),
+					mergeTestEvent("", "Workshop4", "2019-10-11T10:30:00", "01:00"),
+				}},
+			}},
+		},
+	}
+
+	schedule, conflicts := MergeSchedules([]ScheduleSource{
+		{Name: "main", Priority: 0, Schedule: mainSchedule},
+		{Name: "extra", Priority: 1, Schedule: extra},
+	})
+
+	if c := schedule.Conference; c.Title != "Conference Title" || c.End != "2019-10-11" || c.Days != 2 {
+		t.Errorf("Unexpected conference: %+v", c)
+	}
+	titles := func(room Room) []string {
+		var titles []string
+		for _, e := range room.Events {
+			titles = append(titles, e.Title)
+		}
+		return titles
+	}
+	expected := map[string][]string{
+		"2019-10-10/Room1":         {"Workshop", "Event1", "Event2"},
+		"2019-10-10/Workshop Room": {"Workshop2"},
+		"2019-10-11/Workshop Room": {"Workshop3", "Workshop4"},
+	}
+	got := make(map[string][]string)
+	for _, day := range schedule.Days {
+		for _, room := range day.Rooms {
+			got[day.Date+"/"+room.Name] = titles(room)
+		}
+	}
+	if len(got) != len(expected) {
+		t.Fatalf("Unexpected rooms. Got: %v", got)
+	}
+	for key, want := range expected {
+		if len(got[key]) != len(want) {
+			t.Errorf("%v: got %v, expected %v", key, got[key], want)
+			continue
+		}
+		for i := range want {
+			if got[key][i] != want[i] {
+				t.Errorf("%v: got %v, expected %v", key, got[key], want)
+			}
+		}
+	}
+
+	expectedConflicts := []struct {
+		kind, kept, other string
+		dropped           bool
+	}{
+		{"duplicate", "Event1", "Event1 (copy)", true},
+		{"duplicate", "Event2", "Same start", true},
+		{"overlap", "Event1", "Overlap", true},
+		{"overlap", "Workshop3", "Workshop4", false},
+	}
+	if len(conflicts) != len(expectedConflicts) {
+		t.Fatalf("Unexpected conflicts: %v", conflicts)
+	}
+	for i, want := range expectedConflicts {
+		c := conflicts[i]
+		if c.Kind != want.kind || c.Kept.Title != want.kept || c.Other.Title != want.other || c.Dropped != want.dropped {
+			t.Errorf("Unexpected conflict %v: %v", i, c)
+		}
+	}
+
+	// with the extra schedule first, its event wins
+	schedule, _ = MergeSchedules([]ScheduleSource{
+		{Name: "main", Priority: 1, Schedule: mainSchedule},
+		{Name: "extra", Priority: 0, Schedule: extra},
+	})
+	if room := schedule.Days[0].Rooms[0]; room.Name != "Room1" || titles(room)[1] != "Overlap" {
+		t.Errorf("The extra source should win. Got: %v", titles(room))
+	}
+}
+
+func TestParseSchedulePriority(t *testing.T) {
+	if p, err := ParseSchedulePriority("", 3); err != nil || p[0] != 0 || p[1] != 1 || p[2] != 2 {
+		t.Errorf("Unexpected default priorities: %v, %v", p, err)
+	}
+	if p, err := ParseSchedulePriority("3, 1", 3); err != nil || p[0] != 1 || p[1] != 2 || p[2] != 0 {
+		t.Errorf("Unexpected priorities: %v, %v", p, err)
+	}
+	for _, config := range []string{"4", "0", "1,1", "x"} {
+		if _, err := ParseSchedulePriority(config, 3); err == nil {
+			t.Errorf("%q should fail", config)
+		}
+	}
+}
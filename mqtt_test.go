@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bufio"
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+type mqttTestMessage struct {
+	topic   string
+	payload string
+	qos     byte
+	retain  bool
+}
+
+// mqttTestBroker is a minimal embedded broker: it accepts any client and records the publishes
+type mqttTestBroker struct {
+	listener net.Listener
+	mu       sync.Mutex
+	messages []mqttTestMessage
+	connects int
+	username string
+}
+
+func newMQTTTestBroker(t *testing.T, tlsConfig *tls.Config) *mqttTestBroker {
+	var listener net.Listener
+	var err error
+	if tlsConfig != nil {
+		listener, err = tls.Listen("tcp", "127.0.0.1:0", tlsConfig)
+	} else {
+		listener, err = net.Listen("tcp", "127.0.0.1:0")
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b := &mqttTestBroker{listener: listener}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go b.serve(conn)
+		}
+	}()
+	return b
+}
+
+func (b *mqttTestBroker) serve(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+
+	for {
+		typeAndFlags, body, err := readMQTTPacket(reader)
+		if err != nil {
+			return
+		}
+
+		switch typeAndFlags >> 4 {
+		case mqttConnect:
+			b.mu.Lock()
+			b.connects++
+			if flags := body[7]; flags&0x80 != 0 {
+				clientIDLen := int(body[10])<<8 | int(body[11])
+				userStart := 12 + clientIDLen
+				userLen := int(body[userStart])<<8 | int(body[userStart+1])
+				b.username = string(body[userStart+2 : userStart+2+userLen])
+			}
+			b.mu.Unlock()
+			writeMQTTPacket(conn, mqttConnack<<4, []byte{0, 0})
+		case mqttPublish:
+			qos := typeAndFlags >> 1 & 0x03
+			topicLen := int(body[0])<<8 | int(body[1])
+			msg := mqttTestMessage{topic: string(body[2 : 2+topicLen]), qos: qos, retain: typeAndFlags&0x01 != 0}
+			rest := body[2+topicLen:]
+			if qos > 0 {
+				writeMQTTPacket(conn, mqttPuback<<4, rest[:2])
+				rest = rest[2:]
+			}
+			msg.payload = string(rest)
+			b.mu.Lock()
+			b.messages = append(b.messages, msg)
+			b.mu.Unlock()
+		case mqttDisconnect:
+			return
+		}
+	}
+}
+
+func TestMQTTPublisher(t *testing.T) {
+	broker := newMQTTTestBroker(t, nil)
+	defer broker.listener.Close()
+
+	p, err := NewMQTTPublisher("tcp://bot:secret@"+broker.listener.Addr().String(), "conference/eu2019/rooms/{id}", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	if err := p.Publish(RoomInfo{ID: 1, CurrentTitle: "Title1"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Publish(RoomInfo{ID: 2, CurrentTitle: "Title2"}); err != nil {
+		t.Fatal(err)
+	}
+
+	broker.mu.Lock()
+	defer broker.mu.Unlock()
+	if broker.connects != 1 || broker.username != "bot" {
+		t.Errorf("Expected a single connection from user bot. Got %v connections (user: %v)", broker.connects, broker.username)
+	}
+	if len(broker.messages) != 2 {
+		t.Fatalf("Expected 2 messages. Got: %v", broker.messages)
+	}
+	msg := broker.messages[1]
+	if msg.topic != "conference/eu2019/rooms/2" || !msg.retain || msg.qos != 1 {
+		t.Errorf("Unexpected message: %+v", msg)
+	}
+	expected := `{"room_id":2,"room":"","title":"Title2","speaker":"","time":"","n_title":"","n_speaker":"","n_time":"","auto_loop_sec":0}`
+	if msg.payload != expected {
+		t.Errorf("Unexpected payload: %v", msg.payload)
+	}
+}
+
+func TestMQTTPublisherReconnects(t *testing.T) {
+	broker := newMQTTTestBroker(t, nil)
+	defer broker.listener.Close()
+
+	p, _ := NewMQTTPublisher("tcp://"+broker.listener.Addr().String(), "rooms/{id}", 0)
+	defer p.Close()
+	if err := p.Publish(RoomInfo{ID: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	p.conn.Close() // simulate a dropped connection
+	if err := p.Publish(RoomInfo{ID: 1}); err == nil {
+		t.Error("An error was expected on a dropped connection")
+	}
+	if err := p.Publish(RoomInfo{ID: 1}); err != nil {
+		t.Error("Expected to reconnect. Got:", err)
+	}
+
+	broker.mu.Lock()
+	defer broker.mu.Unlock()
+	if broker.connects != 2 {
+		t.Errorf("Expected 2 connections. Got: %v", broker.connects)
+	}
+}
+
+func TestMQTTPublisherTLS(t *testing.T) {
+	// borrow the httptest certificate for the broker
+	server := httptest.NewTLSServer(http.NotFoundHandler())
+	defer server.Close()
+
+	broker := newMQTTTestBroker(t, &tls.Config{Certificates: server.TLS.Certificates})
+	defer broker.listener.Close()
+
+	p, err := NewMQTTPublisher("mqtts://"+broker.listener.Addr().String(), "rooms/{id}", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	p.TLSConfig.RootCAs = x509.NewCertPool()
+	p.TLSConfig.RootCAs.AddCert(server.Certificate())
+
+	if err := p.Publish(RoomInfo{ID: 3}); err != nil {
+		t.Fatal(err)
+	}
+	broker.mu.Lock()
+	defer broker.mu.Unlock()
+	if len(broker.messages) != 1 || broker.messages[0].topic != "rooms/3" {
+		t.Errorf("Unexpected messages: %v", broker.messages)
+	}
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+var payloadFile = GetEnv("PAYLOAD_FILE", "") // HTTP request of the http publishers (see PayloadSchema). Empty posts the RoomInfo JSON
+
+// PayloadSchema defines the HTTP requests of an HTTPPublisher, for receivers other than present-switch:
+//
+//	{"method": "PUT", "url": "{base}/displays/{slug}", "encoding": "form",
+//	 "fields": {"headline": "{{.CurrentTitle}}", "who": "{{.CurrentSpeaker}}"},
+//	 "rooms": {"3": {"url": "http://lobby.local/screen"}}}
+//
+// The body is either the fields (templates), encoded as JSON, form or XML, or the body template.
+type PayloadSchema struct {
+	Method      string                   `json:"method"`       // defaults to POST
+	URL         string                   `json:"url"`          // pattern with {base}, {id}, {slug} and {room}. Defaults to the base URL + room ID
+	Encoding    string                   `json:"encoding"`     // json (default), form or xml
+	Fields      map[string]string        `json:"fields"`       // field templates. Defaults to the RoomInfo fields
+	Body        string                   `json:"body"`         // body template, instead of the fields
+	ContentType string                   `json:"content_type"` // defaults to the one of the encoding
+	XMLRoot     string                   `json:"xml_root"`     // root element of the xml encoding. Defaults to room
+	Rooms       map[string]PayloadSchema `json:"rooms"`        // method and url by room ID
+
+	fields map[string]*template.Template
+	body   *template.Template
+}
+
+// PayloadData is what the payload templates see: the RoomInfo fields, and the room slug
+type PayloadData struct {
+	RoomInfo
+	Slug string
+}
+
+var payloadContentTypes = map[string]string{
+	"json": "application/json",
+	"form": "application/x-www-form-urlencoded",
+	"xml":  "application/xml",
+}
+
+// LoadPayloadSchema reads and checks the payload file. An empty filename means no schema (nil).
+func LoadPayloadSchema(filename string) (*PayloadSchema, error) {
+	if filename == "" {
+		return nil, nil
+	}
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	schema := &PayloadSchema{}
+	if err := json.Unmarshal(data, schema); err != nil {
+		return nil, fmt.Errorf("error parsing the payload %v: %v", filename, err)
+	}
+	if err := schema.parse(); err != nil {
+		return nil, fmt.Errorf("error in the payload %v: %v", filename, err)
+	}
+	return schema, nil
+}
+
+func (s *PayloadSchema) parse() (err error) {
+	if s.Method == "" {
+		s.Method = http.MethodPost
+	}
+	s.Method = strings.ToUpper(s.Method)
+	if s.Encoding == "" {
+		s.Encoding = "json"
+	}
+	if _, ok := payloadContentTypes[s.Encoding]; !ok {
+		return fmt.Errorf("unknown encoding %q. Expected json, form or xml", s.Encoding)
+	}
+	if s.ContentType == "" {
+		s.ContentType = payloadContentTypes[s.Encoding]
+	}
+	if s.XMLRoot == "" {
+		s.XMLRoot = "room"
+	}
+
+	if s.Body != "" {
+		if s.body, err = template.New("body").Funcs(templateFuncs).Parse(s.Body); err != nil {
+			return err
+		}
+	}
+	s.fields = make(map[string]*template.Template)
+	for name, text := range s.Fields {
+		if s.fields[name], err = template.New(name).Funcs(templateFuncs).Parse(text); err != nil {
+			return err
+		}
+	}
+
+	for roomID, room := range s.Rooms {
+		if _, err := strconv.Atoi(roomID); err != nil {
+			return fmt.Errorf("invalid room ID %q", roomID)
+		}
+		if room.Method != "" {
+			room.Method = strings.ToUpper(room.Method)
+			s.Rooms[roomID] = room
+		}
+	}
+	return nil
+}
+
+var slugInvalidChars = regexp.MustCompile(`[^a-z0-9]+`)
+
+// roomSlug returns the room name in lowercase, with dashes, e.g. "main-hall"
+func roomSlug(name string) string {
+	return strings.Trim(slugInvalidChars.ReplaceAllString(strings.ToLower(name), "-"), "-")
+}
+
+// Request returns the method, URL, content type and body of the update of info.
+// baseURL is the one of the publisher.
+func (s *PayloadSchema) Request(baseURL string, info RoomInfo) (method, URL, contentType string, body []byte, err error) {
+	data := PayloadData{RoomInfo: info, Slug: roomSlug(info.RoomName)}
+
+	method, pattern := s.Method, s.URL
+	if room, ok := s.Rooms[strconv.Itoa(info.ID)]; ok {
+		if room.Method != "" {
+			method = room.Method
+		}
+		if room.URL != "" {
+			pattern = room.URL
+		}
+	}
+	if pattern == "" {
+		URL = baseURL + strconv.Itoa(info.ID)
+	} else {
+		URL = strings.NewReplacer(
+			"{base}", strings.TrimSuffix(baseURL, "/"),
+			"{id}", strconv.Itoa(info.ID),
+			"{slug}", data.Slug,
+			"{room}", url.PathEscape(info.RoomName),
+		).Replace(pattern)
+	}
+
+	if s.body != nil {
+		var buf bytes.Buffer
+		if err = s.body.Execute(&buf, data); err != nil {
+			return "", "", "", nil, err
+		}
+		return method, URL, s.ContentType, buf.Bytes(), nil
+	}
+
+	if s.Encoding == "json" && len(s.fields) == 0 {
+		body, err = json.Marshal(info)
+		return method, URL, s.ContentType, body, err
+	}
+	fields, err := s.renderFields(data)
+	if err != nil {
+		return "", "", "", nil, err
+	}
+	switch s.Encoding {
+	case "form":
+		values := url.Values{}
+		for name, value := range fields {
+			values.Set(name, value)
+		}
+		body = []byte(values.Encode())
+	case "xml":
+		body, err = encodeXMLFields(s.XMLRoot, fields)
+	default:
+		body, err = json.Marshal(fields)
+	}
+	return method, URL, s.ContentType, body, err
+}
+
+// renderFields renders the field templates, or returns the RoomInfo fields (as text) if there are none
+func (s *PayloadSchema) renderFields(data PayloadData) (map[string]string, error) {
+	fields := make(map[string]string)
+	if len(s.fields) == 0 {
+		var infoFields map[string]interface{}
+		encoded, _ := json.Marshal(data.RoomInfo)
+		json.Unmarshal(encoded, &infoFields)
+		for name, value := range infoFields {
+			fields[name] = fmt.Sprint(value)
+		}
+		return fields, nil
+	}
+
+	for name, tmpl := range s.fields {
+		var value bytes.Buffer
+		if err := tmpl.Execute(&value, data); err != nil {
+			return nil, err
+		}
+		fields[name] = value.String()
+	}
+	return fields, nil
+}
+
+// encodeXMLFields encodes the fields as <root><name>value</name>...</root>, sorted by name
+func encodeXMLFields(root string, fields map[string]string) ([]byte, error) {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	enc := xml.NewEncoder(&buf)
+	start := xml.StartElement{Name: xml.Name{Local: root}}
+	enc.EncodeToken(start)
+	for _, name := range names {
+		if err := enc.EncodeElement(fields[name], xml.StartElement{Name: xml.Name{Local: name}}); err != nil {
+			return nil, err
+		}
+	}
+	enc.EncodeToken(start.End())
+	if err := enc.Flush(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+func loadTestPayloadSchema(t *testing.T, config string) (*PayloadSchema, error) {
+	filename := filepath.Join(t.TempDir(), "payload.json")
+	if err := ioutil.WriteFile(filename, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return LoadPayloadSchema(filename)
+}
+
+func TestPayloadSchemaRequest(t *testing.T) {
+	info := RoomInfo{ID: 3, RoomName: "Main Hall", CurrentTitle: "Event1 & more", CurrentSpeaker: "PersonName1", AutoLoopSec: 5}
+
+	for _, test := range []struct {
+		config, method, URL, contentType, body string
+	}{
+		{`{}`, "POST", "http://localhost/rooms/3", "application/json",
+			`{"room_id":3,"room":"Main Hall","title":"Event1 \u0026 more","speaker":"PersonName1","time":"","n_title":"","n_speaker":"","n_time":"","auto_loop_sec":5}`},
+		{`{"method": "put", "url": "{base}/displays/{slug}", "encoding": "form", "fields": {"headline": "{{.CurrentTitle}}", "id": "{{.ID}}"}}`,
+			"PUT", "http://localhost/rooms/displays/main-hall", "application/x-www-form-urlencoded", `headline=Event1+%26+more&id=3`},
+		{`{"url": "{base}/{room}", "encoding": "xml", "xml_root": "display", "fields": {"title": "{{.CurrentTitle}}", "by": "{{.CurrentSpeaker}}"}}`,
+			"POST", "http://localhost/rooms/Main%20Hall", "application/xml",
+			`<?xml version="1.0" encoding="UTF-8"?>` + "\n" + `<display><by>PersonName1</by><title>Event1 &amp; more</title></display>`},
+		{`{"body": "{{.RoomName}}: {{.CurrentTitle}}", "content_type": "text/plain", "rooms": {"3": {"method": "PATCH", "url": "http://lobby/screen"}}}`,
+			"PATCH", "http://lobby/screen", "text/plain", `Main Hall: Event1 & more`},
+	} {
+		schema, err := loadTestPayloadSchema(t, test.config)
+		if err != nil {
+			t.Errorf("Unexpected error for %v: %v", test.config, err)
+			continue
+		}
+		method, URL, contentType, body, err := schema.Request("http://localhost/rooms/", info)
+		if err != nil || method != test.method || URL != test.URL || contentType != test.contentType || string(body) != test.body {
+			t.Errorf("Unexpected request for %v\nGot: %v %v %v %v, %v", test.config, method, URL, contentType, string(body), err)
+		}
+	}
+}
+
+func TestLoadPayloadSchemaErrors(t *testing.T) {
+	if schema, err := LoadPayloadSchema(""); schema != nil || err != nil {
+		t.Errorf("No file means no schema. Got: %v, %v", schema, err)
+	}
+	for _, config := range []string{
+		`{"encoding": "yaml"}`,
+		`{"fields": {"title": "{{.CurrentTitle"}}`,
+		`{"body": "{{if}}"}`,
+		`{"rooms": {"main": {"url": "http://lobby/screen"}}}`,
+		`[]`,
+	} {
+		if _, err := loadTestPayloadSchema(t, config); err == nil {
+			t.Errorf("%v should fail", config)
+		}
+	}
+}
+
+func TestHTTPPublisherPayload(t *testing.T) {
+	var gotMethod, gotPath, gotContentType, gotBody string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		gotMethod, gotPath, gotContentType, gotBody = r.Method, r.URL.Path, r.Header.Get("Content-Type"), string(body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	schema, err := loadTestPayloadSchema(t, `{"method": "PUT", "url": "{base}/displays/{slug}", "encoding": "form", "fields": {"title": "{{.CurrentTitle}}"}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := NewHTTPPublisher(server.URL)
+	p.Payload = schema
+	if err := p.Publish(RoomInfo{ID: 1, RoomName: "Room 1", CurrentTitle: "Title"}); err != nil {
+		t.Error("Any 2xx status should succeed. Got:", err)
+	}
+	if gotMethod != "PUT" || gotPath != "/displays/room-1" || gotContentType != "application/x-www-form-urlencoded" || gotBody != "title=Title" {
+		t.Errorf("Unexpected request: %v %v %v %v", gotMethod, gotPath, gotContentType, gotBody)
+	}
+}
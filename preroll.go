@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"log"
+	"sort"
+	"strconv"
+	"text/template"
+	"time"
+)
+
+// RoomInfo.Phase values
+const (
+	phaseSoon = "soon" // the event did not start yet
+)
+
+const defaultPrerollMessage = "{{if .Minutes}}Up next in {{.Minutes}} minutes{{else}}Up next at {{.Time}}{{end}}"
+
+// Preroll announces the events before they start
+type Preroll struct {
+	Minutes []int              // before the start of each event, in descending order. Empty disables it
+	Message *template.Template // renders the RoomInfo.Notice (see PrerollData)
+}
+
+// PrerollData is what the Preroll.Message template sees
+type PrerollData struct {
+	Minutes int    // until the event starts, 0 when waiting for it (e.g. on the morning)
+	Time    string // HH:MM, in the venue timezone
+	Title   string
+	Speaker string
+	Room    string
+}
+
+var eventPreroll = Preroll{
+	Minutes: parseMinutesListEnv("PREROLL_MINUTES", ""),
+	Message: parseTemplateEnv("PREROLL_MESSAGE", defaultPrerollMessage),
+}
+
+// Plan returns the updates of the event of job. Without pre-roll it is just job.
+// If the room waits for the event (the previous one ended before its start), job shows it as
+// starting soon, and another update is sent when it starts. Pre-roll updates are sent Minutes
+// before the start, if they are after job, or after the start of the previous event (notBefore),
+// when there is no wait.
+func (p Preroll) Plan(job Job, notBefore time.Time) []Job {
+	if len(p.Minutes) == 0 {
+		return []Job{job}
+	}
+
+	var jobs []Job
+	start := job
+	waiting := job.At.Before(job.Start)
+	if waiting {
+		notBefore = job.At
+		start.Key += "/start"
+		start.At = job.Start
+		job.Info = p.soon(job.Info, job.Start, 0)
+		jobs = append(jobs, job)
+	}
+
+	for _, minutes := range p.Minutes {
+		at := job.Start.Add(-time.Duration(minutes) * time.Minute)
+		if !at.After(notBefore) {
+			continue
+		}
+		preroll := start
+		preroll.Key = job.Key + "/soon-" + strconv.Itoa(minutes)
+		preroll.At = at
+		preroll.Info = p.soon(start.Info, job.Start, minutes)
+		jobs = append(jobs, preroll)
+		notBefore = at // skips repeated minutes
+	}
+
+	return append(jobs, start)
+}
+
+// soon returns info as starting soon
+func (p Preroll) soon(info RoomInfo, start time.Time, minutes int) RoomInfo {
+	info.Phase = phaseSoon
+	info.StartsAt = start.Format(time.RFC3339)
+	info.Notice = renderNotice(p.Message, PrerollData{
+		Minutes: minutes,
+		Time:    info.CurrentTime,
+		Title:   info.CurrentTitle,
+		Speaker: info.CurrentSpeaker,
+		Room:    info.RoomName,
+	})
+	return info
+}
+
+// renderNotice executes a RoomInfo.Notice template. Errors are logged, and render nothing.
+func renderNotice(tmpl *template.Template, data interface{}) string {
+	var notice bytes.Buffer
+	if err := tmpl.Execute(&notice, data); err != nil {
+		log.Printf("WARNING: Could not render the %v notice. %v\n", tmpl.Name(), err)
+		return ""
+	}
+	return notice.String()
+}
+
+// parseTemplateEnv parses a text/template, falling back to the default one if it is invalid
+func parseTemplateEnv(key, fallback string) *template.Template {
+	tmpl, err := template.New(key).Funcs(templateFuncs).Parse(GetEnv(key, fallback))
+	if err != nil {
+		log.Printf("WARNING: Invalid %v, using %q. %v\n", key, fallback, err)
+		return template.Must(template.New(key).Funcs(templateFuncs).Parse(fallback))
+	}
+	return tmpl
+}
+
+// parseMinutesListEnv parses a list of positive minutes, in descending order
+func parseMinutesListEnv(key, fallback string) []int {
+	var minutes []int
+	for _, value := range parseIntListEnv(key, fallback) {
+		if value <= 0 {
+			log.Printf("WARNING: Ignoring invalid value on %v. %v is not positive\n", key, value)
+			continue
+		}
+		minutes = append(minutes, value)
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(minutes)))
+	return minutes
+}
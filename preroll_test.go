@@ -0,0 +1,84 @@
+package main
+
+import (
+	"testing"
+	"text/template"
+	"time"
+)
+
+func TestPrerollPlan(t *testing.T) {
+	preroll := Preroll{Minutes: []int{15, 5}, Message: template.Must(template.New("test").Parse(defaultPrerollMessage))}
+	schedule := Schedule{Days: []Day{{Date: "2019-10-10", Rooms: []Room{
+		{ID: 1, Name: "Room1", Events: []Event{
+			{GUID: "abc1", Title: "Event1", Date: "2019-10-10T10:00:00Z", Duration: "00:30"},
+			{GUID: "abc2", Title: "Event2", Date: "2019-10-10T10:30:00Z", Duration: "00:30"},
+		}},
+	}}}}
+	defer func(p Preroll) { eventPreroll = p }(eventPreroll)
+	eventPreroll = preroll
+
+	now := time.Date(2019, 10, 10, 8, 0, 0, 0, time.UTC)
+	expected := []struct {
+		key, at, phase, notice string
+	}{
+		{"1/abc1", "08:00", phaseSoon, "Up next at 10:00"},
+		{"1/abc1/soon-15", "09:45", phaseSoon, "Up next in 15 minutes"},
+		{"1/abc1/soon-5", "09:55", phaseSoon, "Up next in 5 minutes"},
+		{"1/abc1/start", "10:00", "", ""},
+		{"1/abc2/soon-15", "10:15", phaseSoon, "Up next in 15 minutes"},
+		{"1/abc2/soon-5", "10:25", phaseSoon, "Up next in 5 minutes"},
+		{"1/abc2", "10:30", "", ""},
+	}
+	jobs := planEventUpdatesAt(schedule, now)
+	if len(jobs) != len(expected) {
+		t.Fatalf("Unexpected jobs: %+v", jobs)
+	}
+	for i, e := range expected {
+		job := jobs[i]
+		if job.Key != e.key || job.At.Format("15:04") != e.at || job.Info.Phase != e.phase || job.Info.Notice != e.notice {
+			t.Errorf("Unexpected job %v. Got: %v %v %q %q", i, job.Key, job.At.Format("15:04"), job.Info.Phase, job.Info.Notice)
+		}
+	}
+	if jobs[0].Info.StartsAt != "2019-10-10T10:00:00Z" || jobs[4].Info.CurrentTitle != "Event2" || jobs[4].Info.StartsAt != "2019-10-10T10:30:00Z" {
+		t.Errorf("Pre-roll updates should show the event starting. Got: %+v, %+v", jobs[0].Info, jobs[4].Info)
+	}
+
+	// the first event of a later day is announced from the start of its day
+	schedule.Days = append(schedule.Days, Day{Date: "2019-10-11", Start: "2019-10-11T08:00:00Z", Rooms: []Room{
+		{ID: 1, Name: "Room1", Events: []Event{
+			{GUID: "efg1", Title: "Event3", Date: "2019-10-11T10:00:00Z", Duration: "00:30"},
+		}},
+	}})
+	jobs = planEventUpdatesAt(schedule, now)
+	if len(jobs) != len(expected)+4 {
+		t.Fatalf("Unexpected jobs: %+v", jobs)
+	}
+	for i, e := range []struct {
+		key, at, notice string
+	}{
+		{"1/efg1", "2019-10-11 08:00", "Up next at 10:00"},
+		{"1/efg1/soon-15", "2019-10-11 09:45", "Up next in 15 minutes"},
+		{"1/efg1/soon-5", "2019-10-11 09:55", "Up next in 5 minutes"},
+		{"1/efg1/start", "2019-10-11 10:00", ""},
+	} {
+		job := jobs[len(expected)+i]
+		if job.Key != e.key || job.At.Format("2006-01-02 15:04") != e.at || job.Info.Notice != e.notice {
+			t.Errorf("Unexpected job %v. Got: %v %v %q", i, job.Key, job.At.Format("2006-01-02 15:04"), job.Info.Notice)
+		}
+	}
+	schedule.Days = schedule.Days[:1]
+
+	// restarting during the pre-roll of Event2 shows it
+	now = time.Date(2019, 10, 10, 10, 20, 0, 0, time.UTC)
+	jobs = catchUpUpdates(planEventUpdatesAt(schedule, now), now)
+	if len(jobs) != 3 || jobs[0].Key != "1/abc2/soon-15" || !jobs[0].At.Equal(now) || jobs[1].Key != "1/abc2/soon-5" || jobs[2].Key != "1/abc2" {
+		t.Errorf("Unexpected catch-up: %+v", jobs)
+	}
+}
+
+func TestPrerollDisabled(t *testing.T) {
+	job := Job{Key: "1/abc1", At: time.Now(), Start: time.Now().Add(time.Hour)}
+	if jobs := (Preroll{}).Plan(job, time.Time{}); len(jobs) != 1 || jobs[0] != job {
+		t.Errorf("Without minutes, the job should be kept. Got: %+v", jobs)
+	}
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Publisher sends a RoomInfo update somewhere (present-switch, a log, another display system...)
+type Publisher interface {
+	Publish(info RoomInfo) error
+}
+
+// publisher is the configured Publisher (see PUBLISHERS)
+var publisher Publisher = NewHTTPPublisher(externalUpdateURL)
+
+// StatusError is returned when a publisher gets an unexpected status code
+type StatusError struct {
+	URL        string
+	StatusCode int
+	Status     string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("error: unexpected status %v from %v", e.Status, e.URL)
+}
+
+// HTTPPublisher POSTs the RoomInfo as JSON to BaseURL + room ID (see present-switch),
+// or sends the request defined by Payload
+type HTTPPublisher struct {
+	BaseURL string
+	Client  *http.Client
+	Payload *PayloadSchema // nil for present-switch
+}
+
+// NewHTTPPublisher creates an HTTPPublisher for baseURL
+func NewHTTPPublisher(baseURL string) *HTTPPublisher {
+	return &HTTPPublisher{BaseURL: baseURL, Client: http.DefaultClient}
+}
+
+// Publish POSTs the RoomInfo. Non 200 responses (non 2xx with a Payload) return a *StatusError.
+func (p *HTTPPublisher) Publish(info RoomInfo) error {
+	if p.Payload != nil {
+		return p.publishPayload(info)
+	}
+
+	body, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+
+	URL := p.BaseURL + strconv.Itoa(info.ID)
+	log.Printf("CALL POST %v - %v\n", URL, string(body))
+	resp, err := p.Client.Post(URL, "application/json", bytes.NewBuffer(body))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return &StatusError{URL: URL, StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+	return nil
+}
+
+func (p *HTTPPublisher) publishPayload(info RoomInfo) error {
+	method, URL, contentType, body, err := p.Payload.Request(p.BaseURL, info)
+	if err != nil {
+		return fmt.Errorf("error: could not build the payload of room %v: %v", info.ID, err)
+	}
+	req, err := http.NewRequest(method, URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+
+	log.Printf("CALL %v %v - %v\n", method, URL, string(body))
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return &StatusError{URL: URL, StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+	return nil
+}
+
+// JSONLinesPublisher writes each RoomInfo as a JSON line
+type JSONLinesPublisher struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewJSONLinesPublisher creates a JSONLinesPublisher writing into w
+func NewJSONLinesPublisher(w io.Writer) *JSONLinesPublisher {
+	return &JSONLinesPublisher{w: w}
+}
+
+// NewFilePublisher creates a JSONLinesPublisher appending to the file (created if needed)
+func NewFilePublisher(filename string) (*JSONLinesPublisher, error) {
+	file, err := os.OpenFile(filename, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return NewJSONLinesPublisher(file), nil
+}
+
+// Publish writes the RoomInfo line
+func (p *JSONLinesPublisher) Publish(info RoomInfo) error {
+	line, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	_, err = p.w.Write(line)
+	return err
+}
+
+// MultiPublisher publishes to all its publishers
+type MultiPublisher []Publisher
+
+// MultiPublishError has the publishers that failed, and their errors
+type MultiPublishError struct {
+	Failed MultiPublisher
+	Errs   []error
+}
+
+func (e *MultiPublishError) Error() string {
+	msgs := make([]string, len(e.Errs))
+	for i, err := range e.Errs {
+		msgs[i] = err.Error()
+	}
+	return fmt.Sprintf("error: %v of the publishers failed: %v", len(e.Errs), strings.Join(msgs, "; "))
+}
+
+// Publish publishes to all publishers, even if some of them fail.
+// Failures are returned in a *MultiPublishError.
+func (m MultiPublisher) Publish(info RoomInfo) error {
+	var multiErr MultiPublishError
+	for _, p := range m {
+		if err := p.Publish(info); err != nil {
+			multiErr.Failed = append(multiErr.Failed, p)
+			multiErr.Errs = append(multiErr.Errs, err)
+		}
+	}
+	if len(multiErr.Errs) > 0 {
+		return &multiErr
+	}
+	return nil
+}
+
+// NewPublisherFromConfig creates the publishers from a comma separated list of "type[:argument]":
+//
+//	http[:baseURL]   POST to present-switch (baseURL defaults to EXTERNAL_UPDATE_URL), or as PAYLOAD_FILE defines
+//	stdout           JSON lines on the standard output
+//	file:filename    JSON lines appended to filename
+//	mqtt:brokerURL   retained JSON messages on MQTT_TOPIC (see NewMQTTPublisher)
+//	server           streams to displays connected to SERVER_ADDR (see RoomHub)
+//
+// More than one publisher results in a MultiPublisher.
+func NewPublisherFromConfig(config string, conference Conference) (Publisher, error) {
+	var publishers MultiPublisher
+	payload, err := LoadPayloadSchema(payloadFile)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, spec := range strings.Split(config, ",") {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+		kind, arg := spec, ""
+		if i := strings.Index(spec, ":"); i >= 0 {
+			kind, arg = spec[:i], spec[i+1:]
+		}
+
+		switch kind {
+		case "http":
+			if arg == "" {
+				arg = externalUpdateURL
+			}
+			p := NewHTTPPublisher(arg)
+			p.Payload = payload
+			publishers = append(publishers, p)
+		case "stdout":
+			publishers = append(publishers, NewJSONLinesPublisher(os.Stdout))
+		case "file":
+			if arg == "" {
+				return nil, fmt.Errorf("error: missing filename for publisher %q", spec)
+			}
+			p, err := NewFilePublisher(arg)
+			if err != nil {
+				return nil, err
+			}
+			publishers = append(publishers, p)
+		case "mqtt":
+			topic := strings.Replace(mqttTopic, "{acronym}", conference.Acronym, -1)
+			p, err := NewMQTTPublisher(arg, topic, mqttQoS)
+			if err != nil {
+				return nil, err
+			}
+			publishers = append(publishers, p)
+		case "server":
+			if serverAddr == "" {
+				return nil, fmt.Errorf("error: publisher %q needs SERVER_ADDR", spec)
+			}
+			publishers = append(publishers, roomHub)
+		default:
+			return nil, fmt.Errorf("error: unknown publisher %q", spec)
+		}
+	}
+
+	if serverAddr != "" && !hasPublisher(publishers, roomHub) {
+		// otherwise the displays connected to the server would never get an update
+		log.Println("WARNING: SERVER_ADDR is set without the server publisher. Adding it")
+		publishers = append(publishers, roomHub)
+	}
+
+	switch len(publishers) {
+	case 0:
+		return nil, fmt.Errorf("error: no publishers configured")
+	case 1:
+		return publishers[0], nil
+	}
+	return publishers, nil
+}
+
+func hasPublisher(publishers MultiPublisher, p Publisher) bool {
+	for _, publisher := range publishers {
+		if publisher == p {
+			return true
+		}
+	}
+	return false
+}
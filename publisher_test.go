@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+type failingPublisher struct{ err error }
+
+func (p failingPublisher) Publish(RoomInfo) error { return p.err }
+
+func TestHTTPPublisher(t *testing.T) {
+	var gotPath, gotBody string
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		gotPath, gotBody = r.URL.Path, string(body)
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	p := NewHTTPPublisher(server.URL + "/rooms/")
+	if err := p.Publish(RoomInfo{ID: 2, CurrentTitle: "Title"}); err != nil {
+		t.Error(err)
+	}
+	if gotPath != "/rooms/2" {
+		t.Errorf("Unexpected path: %v", gotPath)
+	}
+	expectedBody := `{"room_id":2,"room":"","title":"Title","speaker":"","time":"","n_title":"","n_speaker":"","n_time":"","auto_loop_sec":0}`
+	if gotBody != expectedBody {
+		t.Errorf("Unexpected body: %v", gotBody)
+	}
+
+	status = http.StatusServiceUnavailable
+	err := p.Publish(RoomInfo{ID: 2})
+	if statusErr, ok := err.(*StatusError); !ok || statusErr.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("A *StatusError was expected. Got: %v", err)
+	}
+}
+
+func TestJSONLinesPublisher(t *testing.T) {
+	var buf bytes.Buffer
+	p := NewJSONLinesPublisher(&buf)
+	p.Publish(RoomInfo{ID: 1})
+	p.Publish(RoomInfo{ID: 2})
+
+	if lines := bytes.Count(buf.Bytes(), []byte("\n")); lines != 2 {
+		t.Errorf("Expected 2 lines. Got: %v", buf.String())
+	}
+}
+
+func TestMultiPublisher(t *testing.T) {
+	var buf bytes.Buffer
+	failing := failingPublisher{errors.New("failed")}
+	m := MultiPublisher{NewJSONLinesPublisher(&buf), failing}
+
+	err := m.Publish(RoomInfo{ID: 1})
+	multiErr, ok := err.(*MultiPublishError)
+	if !ok || len(multiErr.Failed) != 1 {
+		t.Fatalf("A *MultiPublishError with 1 failure was expected. Got: %v", err)
+	}
+	if buf.Len() == 0 {
+		t.Error("The other publishers should still get the update")
+	}
+	if retry := (RetryPolicy{}).RetryTarget(m, err); len(retry.(MultiPublisher)) != 1 {
+		t.Errorf("Only the failed publisher should be retried. Got: %v", retry)
+	}
+}
+
+func TestNewPublisherFromConfig(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "rooms.jsonl")
+
+	p, err := NewPublisherFromConfig("http:http://localhost:3000/rooms/, stdout, file:"+filename+",mqtt:tcp://localhost", Conference{Acronym: "eu2019"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, ok := p.(MultiPublisher)
+	if !ok || len(m) != 4 {
+		t.Fatalf("Expected 4 publishers. Got: %#v", p)
+	}
+	if h, ok := m[0].(*HTTPPublisher); !ok || h.BaseURL != "http://localhost:3000/rooms/" {
+		t.Errorf("Unexpected http publisher: %#v", m[0])
+	}
+
+	if mqtt, ok := m[3].(*MQTTPublisher); !ok || mqtt.Topic != "conference/eu2019/rooms/{id}" || mqtt.Addr != "localhost:1883" {
+		t.Errorf("Unexpected mqtt publisher: %#v", m[3])
+	}
+
+	if p, _ = NewPublisherFromConfig("http", Conference{}); p.(*HTTPPublisher).BaseURL != externalUpdateURL {
+		t.Errorf("http publisher should default to EXTERNAL_UPDATE_URL. Got: %#v", p)
+	}
+
+	for _, config := range []string{"", "file", "unknown", "mqtt:http://localhost"} {
+		if _, err := NewPublisherFromConfig(config, Conference{}); err == nil {
+			t.Errorf("An error was expected for %q", config)
+		}
+	}
+
+	// displays connected to SERVER_ADDR get the updates, even without the server publisher
+	defer func(addr string) { serverAddr = addr }(serverAddr)
+	serverAddr = ":8080"
+	if p, _ = NewPublisherFromConfig("stdout", Conference{}); !hasPublisher(p.(MultiPublisher), roomHub) {
+		t.Errorf("The server publisher should be added with SERVER_ADDR. Got: %#v", p)
+	}
+	if p, _ = NewPublisherFromConfig("server", Conference{}); p != roomHub {
+		t.Errorf("The server publisher should not be added twice. Got: %#v", p)
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// loadedSchedule is the last schedule loaded, before any RoomShift
+var loadedSchedule struct {
+	sync.Mutex
+	schedule Schedule
+}
+
+func setLoadedSchedule(schedule Schedule) {
+	loadedSchedule.Lock()
+	loadedSchedule.schedule = schedule
+	loadedSchedule.Unlock()
+}
+
+func getLoadedSchedule() Schedule {
+	loadedSchedule.Lock()
+	defer loadedSchedule.Unlock()
+	return loadedSchedule.schedule
+}
+
+// replanMu serializes the replans (schedule reloads and admin shifts), so they do not
+// diff against the same pending updates at once
+var replanMu sync.Mutex
+
+// replanSchedule plans the loaded schedule (with the room shifts applied) again,
+// and reschedules the updates that changed
+func replanSchedule() {
+	replanMu.Lock()
+	defer replanMu.Unlock()
+
+	now := clock.Now()
+	jobs := catchUpUpdates(planEventUpdatesAt(roomShifts.Apply(getLoadedSchedule()), now), now)
+	changed, cancelled, corrected := rescheduleChangedUpdates(scheduler, roomStates, jobs, now)
+	log.Printf("Schedule replanned: %v updates changed, %v cancelled, %v rooms corrected\n", changed, cancelled, corrected)
+}
+
+// watchSchedule reloads the schedule every interval and reschedules the
+// room updates that changed. It never returns.
+func watchSchedule(interval time.Duration, load func() (Schedule, error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		schedule, err := load()
+		if err != nil {
+			log.Println("WARNING: Could not reload the schedule. Keeping the current one.", err)
+			continue
+		}
+
+		log.Println("Schedule reloaded")
+		setLoadedSchedule(schedule)
+		replanSchedule()
+	}
+}
+
+// sameJob compares everything that ends up on the room display
+func sameJob(a, b Job) bool {
+	return a.At.Equal(b.At) && a.Info == b.Info
+}
+
+// rescheduleChangedUpdates diffs the jobs planned from a new schedule against
+// the pending ones. Jobs that are no longer planned get cancelled, and only new
+// or changed jobs are (re)scheduled.
+// Jobs that are already due only matter for what a room should show now: if the
+// room is showing something else, the right update is sent straight away.
+func rescheduleChangedUpdates(s *Scheduler, states *RoomStates, jobs []Job, now time.Time) (changed, cancelled, corrected int) {
+	planned := make(map[string]Job, len(jobs))
+	for _, job := range jobs {
+		planned[job.Key] = job
+	}
+	current := currentUpdates(jobs, now)
+
+	for _, pending := range s.Pending() {
+		if pending.Override {
+			continue // manual updates are not part of the schedule
+		}
+		job, ok := planned[pending.Key]
+		if !ok || (!job.At.After(now) && current[job.RoomID].Key != job.Key) {
+			s.Cancel(pending.Key)
+			cancelled++
+		}
+	}
+
+	for _, job := range jobs {
+		if !job.At.After(now) {
+			continue
+		}
+		if pending, ok := s.Get(job.Key); ok && sameJob(pending, job) {
+			continue
+		}
+		s.Schedule(job)
+		changed++
+	}
+
+	for roomID, job := range current {
+		if pending, ok := s.Get(job.Key); ok {
+			// not sent yet, but about to be
+			if pending.Info != job.Info {
+				job.At = now
+				s.Schedule(job)
+				changed++
+			}
+			continue
+		}
+		if shown, ok := states.Scheduled(roomID); ok && shown.Info == job.Info {
+			continue
+		}
+		log.Printf("Room %v is not showing the right event. Sending %v now\n", roomID, job.Key)
+		job.At = now
+		s.Schedule(job)
+		corrected++
+	}
+
+	return changed, cancelled, corrected
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRescheduleChangedUpdates(t *testing.T) {
+	now := time.Now()
+	s := NewScheduler(func(Job) {})
+	states := NewRoomStates()
+
+	// room 1 is showing "a", room 2 is showing "c"
+	states.Set(Job{Key: "1/a", RoomID: 1, At: now.Add(-time.Hour), Info: RoomInfo{ID: 1, CurrentTitle: "a"}})
+	states.Set(Job{Key: "2/c", RoomID: 2, At: now.Add(-time.Hour), Info: RoomInfo{ID: 2, CurrentTitle: "c"}})
+	s.Schedule(Job{Key: "1/b", RoomID: 1, At: now.Add(time.Hour), Info: RoomInfo{ID: 1, CurrentTitle: "b"}})
+	s.Schedule(Job{Key: "1/cancelled", RoomID: 1, At: now.Add(2 * time.Hour), Info: RoomInfo{ID: 1, CurrentTitle: "x"}})
+	s.Schedule(Job{Key: "2/d", RoomID: 2, At: now.Add(time.Hour), Info: RoomInfo{ID: 2, CurrentTitle: "d"}})
+
+	jobs := []Job{
+		// room 1: unchanged
+		{Key: "1/a", RoomID: 1, At: now.Add(-time.Hour), Info: RoomInfo{ID: 1, CurrentTitle: "a"}},
+		{Key: "1/b", RoomID: 1, At: now.Add(time.Hour), Info: RoomInfo{ID: 1, CurrentTitle: "b"}},
+		// room 2: current talk was replaced, and next one moved
+		{Key: "2/c2", RoomID: 2, At: now.Add(-time.Hour), Info: RoomInfo{ID: 2, CurrentTitle: "c2"}},
+		{Key: "2/d", RoomID: 2, At: now.Add(30 * time.Minute), Info: RoomInfo{ID: 2, CurrentTitle: "d"}},
+	}
+
+	changed, cancelled, corrected := rescheduleChangedUpdates(s, states, jobs, now)
+	if changed != 1 || cancelled != 1 || corrected != 1 {
+		t.Errorf("Unexpected diff. Got changed=%v cancelled=%v corrected=%v", changed, cancelled, corrected)
+	}
+
+	if _, ok := s.Get("1/cancelled"); ok {
+		t.Error("Job not in the new schedule should be cancelled")
+	}
+	if job, ok := s.Get("2/d"); !ok || !job.At.Equal(now.Add(30*time.Minute)) {
+		t.Errorf("Moved job should be rescheduled. Got: %v", job)
+	}
+	if job, ok := s.Get("2/c2"); !ok || !job.At.Equal(now) {
+		t.Errorf("Room 2 should get a corrective update now. Got: %v", job)
+	}
+	if _, ok := s.Get("1/a"); ok {
+		t.Error("Room 1 is showing the right event and should not be updated")
+	}
+}
+
+func TestReplanScheduleIsSerialized(t *testing.T) {
+	defer func(s *Scheduler) { scheduler = s }(scheduler)
+	scheduler = NewScheduler(func(Job) {})
+
+	replanMu.Lock() // e.g. a schedule reload replanning
+	done := make(chan struct{})
+	go func() {
+		replanSchedule() // e.g. an admin shift
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		t.Error("A replan should wait for the running one")
+	case <-time.After(50 * time.Millisecond):
+	}
+	replanMu.Unlock()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Error("The replan should run after the other one")
+	}
+}
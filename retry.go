@@ -0,0 +1,130 @@
+package main
+
+import (
+	"log"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RetryPolicy defines how failed room updates are retried
+type RetryPolicy struct {
+	MaxAttempts int           // including the first one
+	Backoff     time.Duration // delay before the first retry. Doubles on each retry
+	MaxBackoff  time.Duration
+	Jitter      float64 // fraction of the delay randomly added or subtracted (0 to 1)
+	StatusCodes []int   // status codes to retry (see StatusError). Other errors are always retried
+}
+
+var updateRetryPolicy = RetryPolicy{
+	MaxAttempts: parseIntEnv("UPDATE_RETRY_MAX_ATTEMPTS", 5),
+	Backoff:     parseDurationEnv("UPDATE_RETRY_BACKOFF", time.Second),
+	MaxBackoff:  parseDurationEnv("UPDATE_RETRY_MAX_BACKOFF", time.Minute),
+	Jitter:      parseFloatEnv("UPDATE_RETRY_JITTER", 0.2),
+	StatusCodes: parseIntListEnv("UPDATE_RETRY_STATUS_CODES", "408,429,500,502,503,504"),
+}
+
+// ShouldRetry tells if another attempt should be made after attempt failed with err
+func (p RetryPolicy) ShouldRetry(attempt int, err error) bool {
+	if attempt >= p.MaxAttempts {
+		return false
+	}
+	return p.retryable(err)
+}
+
+func (p RetryPolicy) retryable(err error) bool {
+	switch e := err.(type) {
+	case *StatusError:
+		for _, code := range p.StatusCodes {
+			if code == e.StatusCode {
+				return true
+			}
+		}
+		return false
+	case *MultiPublishError:
+		for _, err := range e.Errs {
+			if p.retryable(err) {
+				return true
+			}
+		}
+		return false
+	}
+	return true // transport errors
+}
+
+// RetryTarget returns the publishers to retry after target failed with err: with a
+// *MultiPublishError, only the ones that failed with a retryable error
+func (p RetryPolicy) RetryTarget(target Publisher, err error) Publisher {
+	multiErr, ok := err.(*MultiPublishError)
+	if !ok {
+		return target
+	}
+	var retry MultiPublisher
+	for i, err := range multiErr.Errs {
+		if p.retryable(err) {
+			retry = append(retry, multiErr.Failed[i])
+		}
+	}
+	return retry
+}
+
+// Delay returns how long to wait after the given failed attempt (starting in 1)
+func (p RetryPolicy) Delay(attempt int) time.Duration {
+	delay := p.Backoff
+	for i := 1; i < attempt && (p.MaxBackoff == 0 || delay < p.MaxBackoff); i++ {
+		delay *= 2
+	}
+	if p.MaxBackoff > 0 && delay > p.MaxBackoff {
+		delay = p.MaxBackoff
+	}
+
+	if p.Jitter > 0 {
+		delay += time.Duration(float64(delay) * p.Jitter * (2*rand.Float64() - 1))
+	}
+	return delay
+}
+
+func parseIntEnv(key string, fallback int) int {
+	value, err := strconv.Atoi(GetEnv(key, strconv.Itoa(fallback)))
+	if err != nil {
+		log.Printf("WARNING: Invalid %v, using %v. %v\n", key, fallback, err)
+		return fallback
+	}
+	return value
+}
+
+func parseFloatEnv(key string, fallback float64) float64 {
+	value, err := strconv.ParseFloat(GetEnv(key, strconv.FormatFloat(fallback, 'f', -1, 64)), 64)
+	if err != nil {
+		log.Printf("WARNING: Invalid %v, using %v. %v\n", key, fallback, err)
+		return fallback
+	}
+	return value
+}
+
+func parseDurationEnv(key string, fallback time.Duration) time.Duration {
+	value, err := time.ParseDuration(GetEnv(key, fallback.String()))
+	if err != nil {
+		log.Printf("WARNING: Invalid %v, using %v. %v\n", key, fallback, err)
+		return fallback
+	}
+	return value
+}
+
+// parseIntListEnv parses a comma separated list of integers
+func parseIntListEnv(key, fallback string) []int {
+	var values []int
+	for _, str := range strings.Split(GetEnv(key, fallback), ",") {
+		if str = strings.TrimSpace(str); str == "" {
+			continue
+		}
+		value, err := strconv.Atoi(str)
+		if err != nil {
+			log.Printf("WARNING: Ignoring invalid value on %v. %v\n", key, err)
+			continue
+		}
+		values = append(values, value)
+	}
+	return values
+}
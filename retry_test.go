@@ -0,0 +1,84 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicyShouldRetry(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 3, StatusCodes: []int{500, 503}}
+
+	if !policy.ShouldRetry(1, errors.New("connection refused")) {
+		t.Error("Transport errors should be retried")
+	}
+	if !policy.ShouldRetry(2, &StatusError{StatusCode: 503}) {
+		t.Error("Status 503 should be retried")
+	}
+	if policy.ShouldRetry(1, &StatusError{StatusCode: 404}) {
+		t.Error("Status 404 should not be retried")
+	}
+	if policy.ShouldRetry(3, &StatusError{StatusCode: 500}) {
+		t.Error("Should not retry after MaxAttempts")
+	}
+	if !policy.ShouldRetry(1, &MultiPublishError{Errs: []error{&StatusError{StatusCode: 404}, &StatusError{StatusCode: 500}}}) {
+		t.Error("Should retry if any of the publishers failed with a retryable error")
+	}
+}
+
+func TestRetryPolicyRetryTarget(t *testing.T) {
+	policy := RetryPolicy{StatusCodes: []int{500}}
+	single := failingPublisher{&StatusError{StatusCode: 500}}
+	if target := policy.RetryTarget(single, single.err); target != single {
+		t.Errorf("A single publisher should be retried. Got: %v", target)
+	}
+
+	rejected := failingPublisher{&StatusError{StatusCode: 400}}
+	unavailable := failingPublisher{&StatusError{StatusCode: 500}}
+	disconnected := failingPublisher{errors.New("connection refused")}
+	err := MultiPublisher{rejected, unavailable, disconnected}.Publish(RoomInfo{ID: 1})
+	target, ok := policy.RetryTarget(nil, err).(MultiPublisher)
+	if !ok || len(target) != 2 || target[0] != unavailable || target[1] != disconnected {
+		t.Errorf("Only the publishers with a retryable error should be retried. Got: %v", target)
+	}
+}
+
+func TestRetryPolicyDelay(t *testing.T) {
+	policy := RetryPolicy{Backoff: time.Second, MaxBackoff: 5 * time.Second}
+
+	for attempt, expected := range map[int]time.Duration{
+		1: time.Second,
+		2: 2 * time.Second,
+		3: 4 * time.Second,
+		4: 5 * time.Second,
+		9: 5 * time.Second,
+	} {
+		if delay := policy.Delay(attempt); delay != expected {
+			t.Errorf("Unexpected delay for attempt %v. Got: %v Expected: %v", attempt, delay, expected)
+		}
+	}
+
+	policy.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		if delay := policy.Delay(2); delay < time.Second || delay > 3*time.Second {
+			t.Fatalf("Delay out of the jitter range: %v", delay)
+		}
+	}
+}
+
+func TestRoomStatesDropsOlderUpdates(t *testing.T) {
+	states := NewRoomStates()
+
+	older := Job{Key: "1/a", RoomID: 1, Info: RoomInfo{CurrentTitle: "a"}, Seq: states.Begin(1)}
+	newer := Job{Key: "1/b", RoomID: 1, Info: RoomInfo{CurrentTitle: "b"}, Seq: states.Begin(1)}
+
+	if states.IsLatest(1, older.Seq) || !states.IsLatest(1, newer.Seq) {
+		t.Error("Only the newer update should be the latest")
+	}
+
+	states.Set(newer)
+	states.Set(older) // e.g. a late retry
+	if shown, _ := states.Get(1); shown.Key != "1/b" {
+		t.Errorf("The older update should be ignored. Got: %v", shown.Key)
+	}
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RoomHub is a Publisher keeping the current RoomInfo of each room, and streaming
+// changes to the subscribed displays (see ServeHTTP)
+type RoomHub struct {
+	mu          sync.Mutex
+	current     map[int]RoomInfo
+	subscribers map[int]map[chan RoomInfo]struct{}
+
+	KeepAlive    time.Duration // between keepalives (SSE comments, WebSocket pings), so proxies keep idle connections
+	WriteTimeout time.Duration // to write to a display, before dropping it
+}
+
+// roomHub is the hub served on SERVER_ADDR (see the "server" publisher)
+var roomHub = NewRoomHub()
+
+// NewRoomHub creates an empty RoomHub
+func NewRoomHub() *RoomHub {
+	return &RoomHub{
+		current:      make(map[int]RoomInfo),
+		subscribers:  make(map[int]map[chan RoomInfo]struct{}),
+		KeepAlive:    30 * time.Second,
+		WriteTimeout: 10 * time.Second,
+	}
+}
+
+// Publish stores the RoomInfo as the current one, and sends it to the room subscribers
+func (h *RoomHub) Publish(info RoomInfo) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.current[info.ID] = info
+	for ch := range h.subscribers[info.ID] {
+		sendLatest(ch, info)
+	}
+	return nil
+}
+
+// sendLatest never blocks: a slow subscriber only gets the latest RoomInfo
+func sendLatest(ch chan RoomInfo, info RoomInfo) {
+	for {
+		select {
+		case ch <- info:
+			return
+		default:
+		}
+		select {
+		case <-ch:
+		default:
+		}
+	}
+}
+
+// Current returns the current RoomInfo of the room
+func (h *RoomHub) Current(roomID int) (RoomInfo, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	info, ok := h.current[roomID]
+	return info, ok
+}
+
+// Subscribe returns a channel with the room changes, starting with the current RoomInfo (if any).
+// cancel must be called when done.
+func (h *RoomHub) Subscribe(roomID int) (updates <-chan RoomInfo, cancel func()) {
+	ch := make(chan RoomInfo, 1)
+
+	h.mu.Lock()
+	if info, ok := h.current[roomID]; ok {
+		ch <- info
+	}
+	if h.subscribers[roomID] == nil {
+		h.subscribers[roomID] = make(map[chan RoomInfo]struct{})
+	}
+	h.subscribers[roomID][ch] = struct{}{}
+	h.mu.Unlock()
+
+	return ch, func() {
+		h.mu.Lock()
+		delete(h.subscribers[roomID], ch)
+		h.mu.Unlock()
+	}
+}
+
+// ServeHTTP streams the room changes:
+//
+//	/rooms/{id}/events  as Server-Sent Events
+//	/rooms/{id}/ws      on a WebSocket (one JSON text message per change)
+func (h *RoomHub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) != 3 || parts[0] != "rooms" {
+		http.NotFound(w, r)
+		return
+	}
+	roomID, err := strconv.Atoi(parts[1])
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	switch parts[2] {
+	case "events":
+		h.serveEvents(w, r, roomID)
+	case "ws":
+		h.serveWebSocket(w, r, roomID)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (h *RoomHub) serveEvents(w http.ResponseWriter, r *http.Request, roomID int) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	updates, cancel := h.Subscribe(roomID)
+	defer cancel()
+	keepAlive := time.NewTicker(h.KeepAlive)
+	defer keepAlive.Stop()
+
+	controller := http.NewResponseController(w)
+	write := func(format string, args ...interface{}) error {
+		controller.SetWriteDeadline(time.Now().Add(h.WriteTimeout))
+		if _, err := fmt.Fprintf(w, format, args...); err != nil {
+			return err
+		}
+		flusher.Flush()
+		return nil
+	}
+
+	for {
+		select {
+		case info := <-updates:
+			data, err := json.Marshal(info)
+			if err != nil {
+				log.Println("Could not marshal roomInfo", err)
+				continue
+			}
+			if err := write("data: %s\n\n", data); err != nil {
+				return
+			}
+		case <-keepAlive.C:
+			if err := write(": keepalive\n\n"); err != nil {
+				return
+			}
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func (h *RoomHub) serveWebSocket(w http.ResponseWriter, r *http.Request, roomID int) {
+	ws, err := upgradeWebSocket(w, r)
+	if err != nil {
+		log.Println("WebSocket upgrade failed:", err)
+		return
+	}
+	defer ws.Close()
+	ws.WriteTimeout = h.WriteTimeout
+
+	updates, cancel := h.Subscribe(roomID)
+	defer cancel()
+	keepAlive := time.NewTicker(h.KeepAlive)
+	defer keepAlive.Stop()
+
+	closed := make(chan struct{})
+	go func() {
+		ws.ReadUntilClose()
+		close(closed)
+	}()
+
+	for {
+		select {
+		case info := <-updates:
+			data, err := json.Marshal(info)
+			if err != nil {
+				log.Println("Could not marshal roomInfo", err)
+				continue
+			}
+			if err := ws.WriteText(data); err != nil {
+				return
+			}
+		case <-keepAlive.C:
+			if err := ws.Ping(); err != nil {
+				return
+			}
+		case <-closed:
+			return
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRoomHubSubscribe(t *testing.T) {
+	hub := NewRoomHub()
+	hub.Publish(RoomInfo{ID: 1, CurrentTitle: "First"})
+
+	updates, cancel := hub.Subscribe(1)
+	defer cancel()
+
+	if info := <-updates; info.CurrentTitle != "First" {
+		t.Errorf("New subscribers should get the current state first. Got: %v", info)
+	}
+
+	// slow subscribers only get the latest state
+	hub.Publish(RoomInfo{ID: 1, CurrentTitle: "Second"})
+	hub.Publish(RoomInfo{ID: 1, CurrentTitle: "Third"})
+	hub.Publish(RoomInfo{ID: 2, CurrentTitle: "Other room"})
+	if info := <-updates; info.CurrentTitle != "Third" {
+		t.Errorf("Expected the latest state. Got: %v", info)
+	}
+	select {
+	case info := <-updates:
+		t.Errorf("Unexpected update: %v", info)
+	default:
+	}
+}
+
+func TestRoomHubServerSentEvents(t *testing.T) {
+	hub := NewRoomHub()
+	hub.Publish(RoomInfo{ID: 1, CurrentTitle: "First"})
+	server := httptest.NewServer(hub)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/rooms/1/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("Unexpected Content-Type: %v", ct)
+	}
+
+	reader := bufio.NewReader(resp.Body)
+	readData := func() RoomInfo {
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.HasPrefix(line, "data: ") {
+				var info RoomInfo
+				json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &info)
+				return info
+			}
+		}
+	}
+
+	if info := readData(); info.CurrentTitle != "First" {
+		t.Errorf("Expected the current state first. Got: %v", info)
+	}
+	hub.Publish(RoomInfo{ID: 1, CurrentTitle: "Second"})
+	if info := readData(); info.CurrentTitle != "Second" {
+		t.Errorf("Expected the new state. Got: %v", info)
+	}
+
+	if resp, _ := http.Get(server.URL + "/rooms/x/events"); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected 404 for an invalid room. Got: %v", resp.Status)
+	}
+}
+
+// readTestWebSocketFrame reads an unmasked server frame
+func readTestWebSocketFrame(t *testing.T, r io.Reader) (byte, string) {
+	var header [2]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		t.Fatal(err)
+	}
+	length := int(header[1] & 0x7F)
+	if length == 126 {
+		var ext [2]byte
+		io.ReadFull(r, ext[:])
+		length = int(binary.BigEndian.Uint16(ext[:]))
+	}
+	payload := make([]byte, length)
+	io.ReadFull(r, payload)
+	return header[0] & 0x0F, string(payload)
+}
+
+func TestRoomHubWebSocket(t *testing.T) {
+	hub := NewRoomHub()
+	hub.Publish(RoomInfo{ID: 2, CurrentTitle: "First"})
+	server := httptest.NewServer(hub)
+	defer server.Close()
+
+	conn, err := net.Dial("tcp", server.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	key := "dGhlIHNhbXBsZSBub25jZQ=="
+	conn.Write([]byte("GET /rooms/2/ws HTTP/1.1\r\nHost: localhost\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n" +
+		"Sec-WebSocket-Key: " + key + "\r\nSec-WebSocket-Version: 13\r\n\r\n"))
+
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols || resp.Header.Get("Sec-WebSocket-Accept") != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("Unexpected handshake response: %v %v", resp.Status, resp.Header)
+	}
+
+	if opcode, msg := readTestWebSocketFrame(t, reader); opcode != wsText || !strings.Contains(msg, `"title":"First"`) {
+		t.Errorf("Expected the current state first. Got: %v %v", opcode, msg)
+	}
+	hub.Publish(RoomInfo{ID: 2, CurrentTitle: "Second"})
+	if _, msg := readTestWebSocketFrame(t, reader); !strings.Contains(msg, `"title":"Second"`) {
+		t.Errorf("Expected the new state. Got: %v", msg)
+	}
+
+	// masked close frame, with an empty payload
+	conn.Write([]byte{0x80 | wsClose, 0x80, 1, 2, 3, 4})
+	if opcode, _ := readTestWebSocketFrame(t, reader); opcode != wsClose {
+		t.Errorf("Expected a close frame back. Got: %v", opcode)
+	}
+}
+
+func TestRoomHubKeepAlive(t *testing.T) {
+	hub := NewRoomHub()
+	hub.KeepAlive = 20 * time.Millisecond
+	server := httptest.NewServer(hub)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/rooms/1/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	line, err := bufio.NewReader(resp.Body).ReadString('\n')
+	if err != nil || line != ": keepalive\n" {
+		t.Errorf("Expected a keepalive comment on an idle stream. Got: %q, %v", line, err)
+	}
+
+	conn, err := net.Dial("tcp", server.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	conn.Write([]byte("GET /rooms/1/ws HTTP/1.1\r\nHost: localhost\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n" +
+		"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\nSec-WebSocket-Version: 13\r\n\r\n"))
+	reader := bufio.NewReader(conn)
+	if _, err := http.ReadResponse(reader, nil); err != nil {
+		t.Fatal(err)
+	}
+	if opcode, _ := readTestWebSocketFrame(t, reader); opcode != wsPing {
+		t.Errorf("Expected a ping on an idle WebSocket. Got: %v", opcode)
+	}
+}
+
+func TestWebSocketWriteTimeout(t *testing.T) {
+	client, server := net.Pipe() // the client never reads
+	defer client.Close()
+	ws := &webSocket{conn: server, WriteTimeout: 20 * time.Millisecond}
+
+	done := make(chan error, 1)
+	go func() { done <- ws.WriteText([]byte("{}")) }()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Error("Writing to a stalled client should fail")
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("Writing to a stalled client should not block")
+	}
+}
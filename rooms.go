@@ -0,0 +1,140 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+var roomsFile = GetEnv("ROOMS_FILE", "") // room mapping (see RoomMapping). Empty numbers the rooms in order
+
+// RoomMapping pins the schedule rooms to IDs, so adding a room does not renumber the displays:
+//
+//	{"rooms": [{"id": 1, "name": "Main Hall", "aliases": ["Auditorium"], "display_name": "Hall"}]}
+type RoomMapping struct {
+	Rooms []RoomConfig `json:"rooms"`
+}
+
+// RoomConfig is a room of the mapping. Names and aliases match ignoring case.
+type RoomConfig struct {
+	ID          int      `json:"id"`
+	Name        string   `json:"name"`
+	Aliases     []string `json:"aliases"`
+	DisplayName string   `json:"display_name"`  // shown instead of the name
+	Exclude     bool     `json:"exclude"`       // no updates for this room
+	SameDayNext bool     `json:"same_day_next"` // do not show the next event when it is on another day
+}
+
+// LoadRoomMapping reads the mapping file. An empty filename means no mapping (nil).
+func LoadRoomMapping(filename string) (*RoomMapping, error) {
+	if filename == "" {
+		return nil, nil
+	}
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	mapping := &RoomMapping{}
+	if err := json.Unmarshal(data, mapping); err != nil {
+		return nil, fmt.Errorf("error parsing the room mapping %v: %v", filename, err)
+	}
+	if err := mapping.validate(); err != nil {
+		return nil, fmt.Errorf("error in the room mapping %v: %v", filename, err)
+	}
+	return mapping, nil
+}
+
+func (m *RoomMapping) validate() error {
+	ids := make(map[int]string)
+	names := make(map[string]string)
+	for _, room := range m.Rooms {
+		if strings.TrimSpace(room.Name) == "" {
+			return fmt.Errorf("room %v has no name", room.ID)
+		}
+		if !room.Exclude {
+			if room.ID <= 0 {
+				return fmt.Errorf("room %q needs an id greater than 0", room.Name)
+			}
+			if other, ok := ids[room.ID]; ok {
+				return fmt.Errorf("rooms %q and %q have the same id %v", other, room.Name, room.ID)
+			}
+			ids[room.ID] = room.Name
+		}
+		for _, name := range append([]string{room.Name}, room.Aliases...) {
+			key := roomMappingKey(name)
+			if other, ok := names[key]; ok {
+				return fmt.Errorf("name %q is used by rooms %q and %q", name, other, room.Name)
+			}
+			names[key] = room.Name
+		}
+	}
+	return nil
+}
+
+func roomMappingKey(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// Find returns the room with the name (or alias)
+func (m *RoomMapping) Find(name string) (RoomConfig, bool) {
+	key := roomMappingKey(name)
+	for _, room := range m.Rooms {
+		for _, n := range append([]string{room.Name}, room.Aliases...) {
+			if roomMappingKey(n) == key {
+				return room, true
+			}
+		}
+	}
+	return RoomConfig{}, false
+}
+
+// Rename gives the rooms of the schedule the name of their room in the mapping, so the
+// aliases of a room are merged as one (see mergeSources)
+func (m *RoomMapping) Rename(schedule *Schedule) {
+	for d := range schedule.Days {
+		for r, room := range schedule.Days[d].Rooms {
+			if config, ok := m.Find(room.Name); ok {
+				schedule.Days[d].Rooms[r].Name = config.Name
+			}
+		}
+	}
+}
+
+// Apply sets the room IDs and names of the schedule, removing the excluded rooms.
+// Rooms not in the mapping are numbered after the highest mapped ID, and returned.
+func (m *RoomMapping) Apply(schedule *Schedule) (unmapped []Room) {
+	maxID := 0
+	for _, room := range m.Rooms {
+		if room.ID > maxID {
+			maxID = room.ID
+		}
+	}
+
+	unmappedIDs := make(map[string]int)
+	for d := range schedule.Days {
+		rooms := schedule.Days[d].Rooms[:0]
+		for _, room := range schedule.Days[d].Rooms {
+			config, ok := m.Find(room.Name)
+			switch {
+			case ok && config.Exclude:
+				continue
+			case ok:
+				room.ID, room.Name, room.SameDayNext = config.ID, config.Name, config.SameDayNext
+				if config.DisplayName != "" {
+					room.Name = config.DisplayName
+				}
+			default:
+				key := roomMappingKey(room.Name)
+				if _, seen := unmappedIDs[key]; !seen {
+					unmappedIDs[key] = maxID + len(unmappedIDs) + 1
+					unmapped = append(unmapped, Room{ID: unmappedIDs[key], Name: room.Name})
+				}
+				room.ID = unmappedIDs[key]
+			}
+			rooms = append(rooms, room)
+		}
+		schedule.Days[d].Rooms = rooms
+	}
+	return unmapped
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestRoomMappingApply(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "rooms.json")
+	config := `{"rooms": [
+		{"id": 5, "name": "Main Hall", "aliases": ["Auditorium", "Hall A"], "display_name": "Hall"},
+		{"id": 2, "name": "Room2", "same_day_next": true},
+		{"name": "Storage", "exclude": true}
+	]}`
+	if err := ioutil.WriteFile(filename, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	mapping, err := LoadRoomMapping(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	schedule := Schedule{Days: []Day{
+		{Rooms: []Room{{Name: "New Room"}, {Name: "auditorium"}, {Name: "Storage"}, {Name: "Room2"}}},
+		{Rooms: []Room{{Name: "Main Hall"}, {Name: "Other Room"}, {Name: "new room"}}},
+	}}
+	unmapped := mapping.Apply(&schedule)
+
+	expected := [][]Room{
+		{{ID: 6, Name: "New Room"}, {ID: 5, Name: "Hall"}, {ID: 2, Name: "Room2"}},
+		{{ID: 5, Name: "Hall"}, {ID: 7, Name: "Other Room"}, {ID: 6, Name: "new room"}},
+	}
+	for d, rooms := range expected {
+		if len(schedule.Days[d].Rooms) != len(rooms) {
+			t.Fatalf("Unexpected rooms on day %v: %+v", d, schedule.Days[d].Rooms)
+		}
+		for r, room := range rooms {
+			if got := schedule.Days[d].Rooms[r]; got.ID != room.ID || got.Name != room.Name {
+				t.Errorf("Unexpected room %v on day %v. Got %v-%v, expected %v-%v", r, d, got.ID, got.Name, room.ID, room.Name)
+			}
+		}
+	}
+	if !schedule.Days[0].Rooms[2].SameDayNext || schedule.Days[0].Rooms[0].SameDayNext {
+		t.Error("Only Room2 should show the next events of the same day")
+	}
+	if len(unmapped) != 2 || unmapped[0].Name != "New Room" || unmapped[1].ID != 7 {
+		t.Errorf("Unexpected unmapped rooms: %+v", unmapped)
+	}
+}
+
+func TestLoadRoomMappingErrors(t *testing.T) {
+	if mapping, err := LoadRoomMapping(""); mapping != nil || err != nil {
+		t.Errorf("No file means no mapping. Got: %v, %v", mapping, err)
+	}
+
+	for _, config := range []string{
+		`{"rooms": [{"id": 1, "name": "Room1"}, {"id": 1, "name": "Room2"}]}`,
+		`{"rooms": [{"id": 1, "name": "Room1"}, {"id": 2, "name": "Room2", "aliases": ["room1"]}]}`,
+		`{"rooms": [{"name": "Room1"}]}`,
+		`{"rooms": [{"id": 1}]}`,
+		`{"rooms": {}}`,
+	} {
+		filename := filepath.Join(t.TempDir(), "rooms.json")
+		ioutil.WriteFile(filename, []byte(config), 0644)
+		if _, err := LoadRoomMapping(filename); err == nil {
+			t.Errorf("%v should fail", config)
+		}
+	}
+}
+
+func TestRoomMappingAcrossSources(t *testing.T) {
+	mapping := &RoomMapping{Rooms: []RoomConfig{{ID: 1, Name: "Main Hall", Aliases: []string{"Auditorium"}}}}
+	sources := []ScheduleSource{
+		{Name: "main", Schedule: Schedule{Days: []Day{{Date: "2019-10-10", Rooms: []Room{
+			{Name: "Main Hall", Events: []Event{mergeTestEvent("a", "A", "2019-10-10T14:00:00Z", "01:00")}},
+		}}}}},
+		{Name: "extra", Priority: 1, Schedule: Schedule{Days: []Day{{Date: "2019-10-10", Rooms: []Room{
+			{Name: "Auditorium", Events: []Event{
+				mergeTestEvent("b", "B", "2019-10-10T10:00:00Z", "01:00"),
+				mergeTestEvent("c", "C", "2019-10-10T14:30:00Z", "01:00"),
+			}},
+		}}}}},
+	}
+
+	schedule, conflicts, unmapped := mergeSources(sources, mapping)
+	if len(conflicts) != 1 || conflicts[0].Kind != "overlap" || conflicts[0].Kept.Title != "A" || conflicts[0].Other.Title != "C" {
+		t.Errorf("The events of an alias should be checked against the room. Got: %+v", conflicts)
+	}
+	rooms := schedule.Days[0].Rooms
+	if len(unmapped) != 0 || len(rooms) != 1 || rooms[0].ID != 1 || rooms[0].Name != "Main Hall" {
+		t.Fatalf("The aliases should be merged into one room. Got: %+v", rooms)
+	}
+	if events := rooms[0].Events; len(events) != 2 || events[0].Title != "B" || events[1].Title != "A" {
+		t.Errorf("Unexpected events: %+v", events)
+	}
+}
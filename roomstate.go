@@ -0,0 +1,138 @@
+package main
+
+import (
+	"sort"
+	"sync"
+)
+
+// RoomStates keeps the last update successfully sent to each room,
+// i.e. what each room is showing right now.
+// While a room is overridden (see Override), scheduled updates are held instead of sent.
+type RoomStates struct {
+	mu         sync.Mutex
+	shown      map[int]Job
+	seq        map[int]uint64 // map(Room.ID) last dispatch sequence
+	overridden map[int]bool
+	held       map[int]Job // map(Room.ID) last scheduled update, while overridden
+}
+
+var roomStates = NewRoomStates()
+
+// NewRoomStates creates an empty RoomStates
+func NewRoomStates() *RoomStates {
+	return &RoomStates{
+		shown:      make(map[int]Job),
+		seq:        make(map[int]uint64),
+		overridden: make(map[int]bool),
+		held:       make(map[int]Job),
+	}
+}
+
+// Begin registers a new update being sent to the room, and returns its sequence
+func (r *RoomStates) Begin(roomID int) uint64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.seq[roomID]++
+	return r.seq[roomID]
+}
+
+// IsLatest tells if seq is the last update sent to the room
+func (r *RoomStates) IsLatest(roomID int, seq uint64) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.seq[roomID] == seq
+}
+
+// Set stores job as the update shown on its room.
+// It is ignored if a newer update was sent to the room meanwhile.
+func (r *RoomStates) Set(job Job) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if job.Seq != 0 && job.Seq != r.seq[job.RoomID] {
+		return
+	}
+	r.shown[job.RoomID] = job
+}
+
+// Get returns the update shown on the room
+func (r *RoomStates) Get(roomID int) (Job, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	job, ok := r.shown[roomID]
+	return job, ok
+}
+
+// Scheduled returns the last update of the schedule for the room.
+// It is the shown one, unless the room is overridden.
+func (r *RoomStates) Scheduled(roomID int) (Job, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.overridden[roomID] {
+		job, ok := r.held[roomID]
+		return job, ok
+	}
+	job, ok := r.shown[roomID]
+	return job, ok
+}
+
+// Override marks the room as overridden: scheduled updates are held until Resume.
+func (r *RoomStates) Override(roomID int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.overridden[roomID] {
+		return
+	}
+	r.overridden[roomID] = true
+	if job, ok := r.shown[roomID]; ok {
+		r.held[roomID] = job
+	}
+}
+
+// Overridden tells if the room is overridden
+func (r *RoomStates) Overridden(roomID int) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.overridden[roomID]
+}
+
+// Hold keeps the job instead of sending it, if its room is overridden.
+// Returns false if the job should be sent.
+func (r *RoomStates) Hold(job Job) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.overridden[job.RoomID] {
+		return false
+	}
+	r.held[job.RoomID] = job
+	return true
+}
+
+// Resume ends the room override, and returns the held update to send (if any)
+func (r *RoomStates) Resume(roomID int) (Job, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	job, ok := r.held[roomID]
+	delete(r.overridden, roomID)
+	delete(r.held, roomID)
+	return job, ok
+}
+
+// Rooms returns the IDs of the rooms with some state, sorted
+func (r *RoomStates) Rooms() []int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	seen := make(map[int]bool)
+	for roomID := range r.shown {
+		seen[roomID] = true
+	}
+	for roomID := range r.held {
+		seen[roomID] = true
+	}
+	rooms := make([]int, 0, len(seen))
+	for roomID := range seen {
+		rooms = append(rooms, roomID)
+	}
+	sort.Ints(rooms)
+	return rooms
+}
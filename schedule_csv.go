@@ -0,0 +1,226 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+var csvColumns = GetEnv("CSV_COLUMNS", "")      // e.g. "date=Day,start=Begins,room=Where". Unset fields use their own name
+var csvDelimiter = GetEnv("CSV_DELIMITER", ",") // "\t" for tab separated files
+var csvDateLayout = GetEnv("CSV_DATE_LAYOUT", "2006-01-02")
+
+// csvFields are the schedule fields a CSV column can be mapped to (see CSV_COLUMNS).
+// Either duration or end is required.
+var csvFields = []string{"date", "start", "duration", "end", "room", "title", "speakers", "description"}
+
+var csvRequiredFields = []string{"date", "start", "room", "title"}
+
+// CSVColumns maps schedule fields to the CSV header names
+type CSVColumns map[string]string
+
+// ParseCSVColumns parses a "field=Header,field=Header" mapping. Unset fields are mapped to their own name.
+func ParseCSVColumns(config string) (CSVColumns, error) {
+	columns := make(CSVColumns)
+	for _, field := range csvFields {
+		columns[field] = field
+	}
+	for _, item := range strings.Split(config, ",") {
+		if strings.TrimSpace(item) == "" {
+			continue
+		}
+		parts := strings.SplitN(item, "=", 2)
+		field := strings.ToLower(strings.TrimSpace(parts[0]))
+		if _, ok := columns[field]; !ok || len(parts) != 2 || strings.TrimSpace(parts[1]) == "" {
+			return nil, fmt.Errorf("error: invalid CSV column mapping %q. Expected field=Header, with field one of %v", item, strings.Join(csvFields, ", "))
+		}
+		columns[field] = strings.TrimSpace(parts[1])
+	}
+	return columns, nil
+}
+
+// parseCSVSchedule parses a CSV file with a header row, one event per row (see CSV_COLUMNS)
+func parseCSVSchedule(body []byte) (Schedule, error) {
+	columns, err := ParseCSVColumns(csvColumns)
+	if err != nil {
+		return Schedule{}, err
+	}
+	delimiter, err := parseCSVDelimiter(csvDelimiter)
+	if err != nil {
+		return Schedule{}, err
+	}
+	return parseCSVScheduleWith(body, columns, delimiter, csvDateLayout)
+}
+
+func parseCSVDelimiter(config string) (rune, error) {
+	if config == `\t` {
+		return '\t', nil
+	}
+	delimiter, size := utf8.DecodeRuneInString(config)
+	if size == 0 || size != len(config) {
+		return 0, fmt.Errorf("error: invalid CSV_DELIMITER %q. Expected a single character", config)
+	}
+	return delimiter, nil
+}
+
+func parseCSVScheduleWith(body []byte, columns CSVColumns, delimiter rune, dateLayout string) (Schedule, error) {
+	reader := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(body, []byte("\xef\xbb\xbf"))))
+	reader.Comma = delimiter
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return Schedule{}, fmt.Errorf("error reading the CSV header: %v", err)
+	}
+	index := csvColumnIndex(header, columns)
+	for _, field := range csvRequiredFields {
+		if _, ok := index[field]; !ok {
+			return Schedule{}, fmt.Errorf("error: CSV column %q (%v) not found in the header %v", columns[field], field, header)
+		}
+	}
+	_, hasDuration := index["duration"]
+	_, hasEnd := index["end"]
+	if !hasDuration && !hasEnd {
+		return Schedule{}, fmt.Errorf("error: CSV column %q (duration) or %q (end) not found in the header %v", columns["duration"], columns["end"], header)
+	}
+
+	type row struct {
+		date  string
+		start time.Time
+		room  string
+		event Event
+	}
+	var rows []row
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return Schedule{}, fmt.Errorf("error reading the CSV: %v", err)
+		}
+		get := func(field string) string {
+			if i, ok := index[field]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		if strings.TrimSpace(strings.Join(record, "")) == "" {
+			continue
+		}
+
+		start, event, err := csvEvent(get, dateLayout)
+		if err != nil {
+			log.Printf("WARNING: Skipping CSV line %v: %v\n", line, err)
+			continue
+		}
+		rows = append(rows, row{date: start.Format("2006-01-02"), start: start, room: get("room"), event: event})
+	}
+	sort.SliceStable(rows, func(i, j int) bool { return rows[i].start.Before(rows[j].start) })
+
+	schedule := Schedule{}
+	for _, r := range rows {
+		if len(schedule.Days) == 0 || schedule.Days[len(schedule.Days)-1].Date != r.date {
+			schedule.Days = append(schedule.Days, Day{Date: r.date, Start: r.event.Date})
+		}
+		room := findRoom(&schedule.Days[len(schedule.Days)-1], r.room)
+		room.Events = append(room.Events, r.event)
+	}
+	if n := len(schedule.Days); n > 0 {
+		schedule.Conference.Start = schedule.Days[0].Date
+		schedule.Conference.End = schedule.Days[n-1].Date
+		schedule.Conference.Days = n
+	}
+	return schedule, nil
+}
+
+// csvColumnIndex finds the column of each field, ignoring case
+func csvColumnIndex(header []string, columns CSVColumns) map[string]int {
+	index := make(map[string]int)
+	for field, name := range columns {
+		for i, column := range header {
+			if strings.EqualFold(strings.TrimSpace(column), name) {
+				index[field] = i
+				break
+			}
+		}
+	}
+	return index
+}
+
+// csvEvent builds the event of a row. Dates have no zone: they are in the venue timezone (see ParseEventTime).
+func csvEvent(get func(field string) string, dateLayout string) (time.Time, Event, error) {
+	for _, field := range csvRequiredFields {
+		if get(field) == "" {
+			return time.Time{}, Event{}, fmt.Errorf("error: missing %v", field)
+		}
+	}
+
+	date, err := time.Parse(dateLayout, get("date"))
+	if err != nil {
+		return time.Time{}, Event{}, fmt.Errorf("error: invalid date %q. Expected the layout %v", get("date"), dateLayout)
+	}
+	startClock, err := parseCSVClock(get("start"))
+	if err != nil {
+		return time.Time{}, Event{}, err
+	}
+	start := date.Add(startClock)
+
+	var duration time.Duration
+	if end := get("end"); end != "" && get("duration") == "" {
+		endClock, err := parseCSVClock(end)
+		if err != nil {
+			return time.Time{}, Event{}, err
+		}
+		if endClock < startClock {
+			endClock += 24 * time.Hour // ends after midnight
+		}
+		duration = endClock - startClock
+	} else if duration, err = parseCSVDuration(get("duration")); err != nil {
+		return time.Time{}, Event{}, err
+	}
+
+	event := Event{
+		Date:        start.Format("2006-01-02T15:04:05"),
+		Title:       get("title"),
+		Start:       start.Format("15:04"),
+		Duration:    FormatCustomDuration(duration),
+		Description: get("description"),
+	}
+	for _, name := range strings.FieldsFunc(get("speakers"), func(r rune) bool { return r == ',' || r == ';' }) {
+		if name = strings.TrimSpace(name); name != "" {
+			event.Persons = append(event.Persons, Person{Name: name})
+		}
+	}
+	return start, event, nil
+}
+
+// parseCSVClock parses a time of day, as HH:MM or HH:MM:SS
+func parseCSVClock(value string) (time.Duration, error) {
+	for _, layout := range []string{"15:04", "15:04:05"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second, nil
+		}
+	}
+	return 0, fmt.Errorf("error: invalid time %q. Expected HH:MM", value)
+}
+
+// parseCSVDuration parses a duration as HH:MM, or minutes (e.g. 45)
+func parseCSVDuration(value string) (time.Duration, error) {
+	if minutes, err := strconv.Atoi(value); err == nil && minutes >= 0 {
+		return time.Duration(minutes) * time.Minute, nil
+	}
+	duration, err := ParseCustomDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("error: invalid duration %q. Expected HH:MM or minutes", value)
+	}
+	return duration, nil
+}
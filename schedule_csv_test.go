@@ -0,0 +1,54 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestParseCSVSchedule(t *testing.T) {
+	csv := "\xef\xbb\xbfDay;Begins;Ends;Where;What;Who\n" +
+		"2019-10-11;14:00;15:30;Workshop Room;Event2;PersonName1, PersonName2\n" +
+		"2019-10-10;10:00;10:45;Workshop Room;\"Event1; with a semicolon\";\n" +
+		";;;;;\n" +
+		"2019-10-10;9:30;10:00;Hall;Event0;PersonName3\n" +
+		"2019-10-10;xx;11:00;Hall;Invalid start;\n"
+
+	columns, err := ParseCSVColumns("date=Day, start=Begins, end=Ends, room=Where, title=What, speakers=Who")
+	if err != nil {
+		t.Fatal(err)
+	}
+	schedule, err := parseCSVScheduleWith([]byte(csv), columns, ';', "2006-01-02")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(schedule.Days) != 2 || schedule.Days[0].Date != "2019-10-10" || schedule.Conference.Days != 2 {
+		t.Fatalf("Unexpected days: %+v", schedule.Days)
+	}
+	rooms := schedule.Days[0].Rooms
+	if len(rooms) != 2 || rooms[0].Name != "Hall" || rooms[1].Name != "Workshop Room" {
+		t.Fatalf("Rooms should be sorted by their first event. Got: %+v", rooms)
+	}
+	if e := rooms[0].Events; len(e) != 1 || e[0].Date != "2019-10-10T09:30:00" || e[0].Start != "09:30" || e[0].Duration != "00:30" {
+		t.Errorf("Unexpected Hall events (the invalid line should be skipped): %+v", e)
+	}
+	if e := rooms[1].Events[0]; e.Title != "Event1; with a semicolon" || len(e.Persons) != 0 {
+		t.Errorf("Unexpected event: %+v", e)
+	}
+	if e := schedule.Days[1].Rooms[0].Events[0]; e.Duration != "01:30" || len(e.Persons) != 2 || e.Persons[1].Name != "PersonName2" {
+		t.Errorf("Unexpected event: %+v", e)
+	}
+
+	// default mapping, duration in minutes or HH:MM
+	columns, _ = ParseCSVColumns("")
+	schedule, err = parseCSVScheduleWith([]byte("Date,Start,Duration,Room,Title\n2019-10-10,10:00,01:15,Hall,Event1\n"), columns, ',', "2006-01-02")
+	if err != nil || schedule.Days[0].Rooms[0].Events[0].Duration != "01:15" {
+		t.Errorf("Unexpected schedule: %+v, %v", schedule, err)
+	}
+
+	if _, err := parseCSVScheduleWith([]byte("date,start,room,title\n"), columns, ',', "2006-01-02"); err == nil {
+		t.Error("A header without duration or end should fail")
+	}
+	if _, err := ParseCSVColumns("place=Where"); err == nil {
+		t.Error("Unknown fields should fail")
+	}
+}
@@ -0,0 +1,566 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+)
+
+var icsSpeakersProperty = GetEnv("ICS_SPEAKERS_PROPERTY", "X-SPEAKERS") // comma separated speakers of a VEVENT
+
+// icsRecurrenceHorizon bounds the recurring events without COUNT or UNTIL, from their DTSTART
+// or the calendar time (see icsCalendarTime), whichever is later
+const icsRecurrenceHorizon = 365 * 24 * time.Hour
+
+// icsComponent is a parsed iCalendar component (VCALENDAR, VEVENT, VTIMEZONE...)
+type icsComponent struct {
+	Name       string
+	Properties []icsProperty
+	Children   []*icsComponent
+}
+
+// icsProperty is a content line, e.g. DTSTART;TZID=Europe/Lisbon:20191010T100000
+type icsProperty struct {
+	Name   string
+	Params map[string]string
+	Value  string
+}
+
+// Get returns the first property with the name
+func (c *icsComponent) Get(name string) (icsProperty, bool) {
+	for _, p := range c.Properties {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return icsProperty{}, false
+}
+
+// All returns every property with the name
+func (c *icsComponent) All(name string) []icsProperty {
+	var props []icsProperty
+	for _, p := range c.Properties {
+		if p.Name == name {
+			props = append(props, p)
+		}
+	}
+	return props
+}
+
+// Text returns the unescaped value of the first property with the name
+func (c *icsComponent) Text(name string) string {
+	p, _ := c.Get(name)
+	return icsText(p.Value)
+}
+
+// icsOccurrence is a VEVENT instance (recurring events have many)
+type icsOccurrence struct {
+	GUID     string
+	Start    time.Time
+	Duration time.Duration
+	Event    *icsComponent
+}
+
+// parseICSSchedule parses an iCalendar file: each VEVENT (or occurrence of a recurring one)
+// is an Event, in the room named by its LOCATION. Events are grouped in days of the venue timezone.
+func parseICSSchedule(body []byte) (Schedule, error) {
+	calendar, err := parseICSComponents(body)
+	if err != nil {
+		return Schedule{}, err
+	}
+
+	schedule := Schedule{Conference: Conference{
+		Title:        calendar.Text("X-WR-CALNAME"),
+		TimeZoneName: calendar.Text("X-WR-TIMEZONE"),
+	}}
+	loc := venueLocation(schedule.Conference)
+	zones := newICSZones(calendar, loc)
+
+	occurrences := icsOccurrences(calendar, zones, icsCalendarTime(calendar, zones))
+	sort.SliceStable(occurrences, func(i, j int) bool {
+		return occurrences[i].Start.Before(occurrences[j].Start)
+	})
+
+	for _, o := range occurrences {
+		roomName := o.Event.Text("LOCATION")
+		if roomName == "" {
+			log.Printf("WARNING: Skipping calendar event %q without LOCATION\n", o.Event.Text("SUMMARY"))
+			continue
+		}
+		start := o.Start.In(loc)
+		event := Event{
+			GUID:        o.GUID,
+			Date:        start.Format(eventDateLayout),
+			Title:       o.Event.Text("SUMMARY"),
+			Start:       start.Format("15:04"),
+			Duration:    FormatCustomDuration(o.Duration),
+			URL:         o.Event.Text("URL"),
+			Type:        o.Event.Text("CATEGORIES"),
+			Description: o.Event.Text("DESCRIPTION"),
+			Persons:     icsSpeakers(o.Event),
+		}
+
+		date := start.Format("2006-01-02")
+		if len(schedule.Days) == 0 || schedule.Days[len(schedule.Days)-1].Date != date {
+			schedule.Days = append(schedule.Days, Day{Date: date, Start: event.Date})
+		}
+		day := &schedule.Days[len(schedule.Days)-1]
+		if end := start.Add(o.Duration).Format(eventDateLayout); end > day.End {
+			day.End = end
+		}
+		room := findRoom(day, roomName)
+		room.Events = append(room.Events, event)
+	}
+
+	if n := len(schedule.Days); n > 0 {
+		schedule.Conference.Start = schedule.Days[0].Date
+		schedule.Conference.End = schedule.Days[n-1].Date
+		schedule.Conference.Days = n
+	}
+	return schedule, nil
+}
+
+// findRoom returns the day room with the name, adding it if needed
+func findRoom(day *Day, name string) *Room {
+	for r := range day.Rooms {
+		if day.Rooms[r].Name == name {
+			return &day.Rooms[r]
+		}
+	}
+	day.Rooms = append(day.Rooms, Room{Name: name})
+	return &day.Rooms[len(day.Rooms)-1]
+}
+
+// icsCalendarTime returns when the calendar was exported: its latest DTSTAMP or LAST-MODIFIED.
+// Recurrences are expanded from it, so the same calendar always parses the same.
+func icsCalendarTime(calendar *icsComponent, zones *icsZones) time.Time {
+	var latest time.Time
+	for _, c := range calendar.Children {
+		if c.Name != "VEVENT" {
+			continue
+		}
+		for _, name := range []string{"DTSTAMP", "LAST-MODIFIED"} {
+			p, ok := c.Get(name)
+			if !ok {
+				continue
+			}
+			if t, _, err := zones.parse(p); err == nil && t.After(latest) {
+				latest = t
+			}
+		}
+	}
+	return latest
+}
+
+// icsOccurrences expands the VEVENTs of the calendar. Cancelled events, all-day events and
+// occurrences replaced by another VEVENT (RECURRENCE-ID) are left out.
+func icsOccurrences(calendar *icsComponent, zones *icsZones, calendarTime time.Time) []icsOccurrence {
+	var events []*icsComponent
+	replaced := make(map[string]bool) // UID/start of the occurrences with their own VEVENT
+	for _, c := range calendar.Children {
+		if c.Name != "VEVENT" {
+			continue
+		}
+		events = append(events, c)
+		if p, ok := c.Get("RECURRENCE-ID"); ok {
+			if recurrenceID, _, err := zones.parse(p); err == nil {
+				replaced[icsOccurrenceGUID(c.Text("UID"), recurrenceID)] = true
+			}
+		}
+	}
+
+	var occurrences []icsOccurrence
+	for _, event := range events {
+		if strings.EqualFold(event.Text("STATUS"), "CANCELLED") {
+			continue
+		}
+		expanded, err := expandICSEvent(event, zones, calendarTime)
+		if err != nil {
+			log.Printf("WARNING: Skipping calendar event %q: %v\n", event.Text("SUMMARY"), err)
+			continue
+		}
+		_, isOverride := event.Get("RECURRENCE-ID")
+		for _, o := range expanded {
+			if !isOverride && replaced[o.GUID] {
+				continue
+			}
+			occurrences = append(occurrences, o)
+		}
+	}
+	return occurrences
+}
+
+// expandICSEvent returns the occurrences of a VEVENT (one, unless it has RRULE/RDATE)
+func expandICSEvent(event *icsComponent, zones *icsZones, calendarTime time.Time) ([]icsOccurrence, error) {
+	dtstart, ok := event.Get("DTSTART")
+	if !ok {
+		return nil, fmt.Errorf("error: missing DTSTART")
+	}
+	if isICSDate(dtstart) {
+		return nil, fmt.Errorf("error: all-day events are not supported")
+	}
+	wall, zone, err := zones.wall(dtstart)
+	if err != nil {
+		return nil, err
+	}
+	start := zone(wall)
+
+	duration, err := icsEventDuration(event, start, zones)
+	if err != nil {
+		return nil, err
+	}
+
+	uid := event.Text("UID")
+	_, isOverride := event.Get("RECURRENCE-ID")
+	rrule, hasRule := event.Get("RRULE")
+	rdates := event.All("RDATE")
+	if !hasRule && len(rdates) == 0 && !isOverride {
+		return []icsOccurrence{{GUID: uid, Start: start, Duration: duration, Event: event}}, nil
+	}
+
+	// occurrences are named after their original start, so they keep their key when the calendar changes
+	if isOverride {
+		p, _ := event.Get("RECURRENCE-ID")
+		recurrenceID, _, err := zones.parse(p)
+		if err != nil {
+			return nil, err
+		}
+		return []icsOccurrence{{GUID: icsOccurrenceGUID(uid, recurrenceID), Start: start, Duration: duration, Event: event}}, nil
+	}
+
+	starts := []time.Time{start}
+	if hasRule {
+		rule, err := parseICSRecurrence(rrule.Value, dtstart.Params, zones)
+		if err != nil {
+			return nil, err
+		}
+		horizon := start
+		if calendarTime.After(horizon) {
+			horizon = calendarTime
+		}
+		starts = nil
+		for _, w := range rule.Expand(wall, zone, horizon.Add(icsRecurrenceHorizon)) {
+			starts = append(starts, zone(w))
+		}
+	}
+	for _, p := range rdates {
+		for _, value := range strings.Split(p.Value, ",") {
+			rdate, _, err := zones.parse(icsProperty{Name: p.Name, Params: p.Params, Value: value})
+			if err != nil {
+				return nil, err
+			}
+			starts = append(starts, rdate)
+		}
+	}
+
+	excluded, err := icsExcludedDates(event, zones)
+	if err != nil {
+		return nil, err
+	}
+
+	var occurrences []icsOccurrence
+	seen := make(map[int64]bool)
+	for _, s := range starts {
+		if seen[s.Unix()] || excluded.has(s, zones.floating) {
+			continue
+		}
+		seen[s.Unix()] = true
+		occurrences = append(occurrences, icsOccurrence{GUID: icsOccurrenceGUID(uid, s), Start: s, Duration: duration, Event: event})
+	}
+	return occurrences, nil
+}
+
+func icsOccurrenceGUID(uid string, start time.Time) string {
+	if uid == "" {
+		return ""
+	}
+	return uid + "/" + start.UTC().Format("20060102T150405Z")
+}
+
+// icsEventDuration uses DTEND, or DURATION
+func icsEventDuration(event *icsComponent, start time.Time, zones *icsZones) (time.Duration, error) {
+	if p, ok := event.Get("DTEND"); ok {
+		end, _, err := zones.parse(p)
+		if err != nil {
+			return 0, err
+		}
+		if end.Before(start) {
+			return 0, fmt.Errorf("error: DTEND %v is before DTSTART", p.Value)
+		}
+		return end.Sub(start), nil
+	}
+	if p, ok := event.Get("DURATION"); ok {
+		duration, err := parseICSDuration(p.Value)
+		if err == nil && duration < 0 {
+			err = fmt.Errorf("error: negative DURATION %v", p.Value)
+		}
+		return duration, err
+	}
+	return 0, nil
+}
+
+// icsDates is a set of EXDATEs, by instant (or by date, for VALUE=DATE)
+type icsDates struct {
+	instants map[int64]bool
+	dates    map[string]bool
+}
+
+func (d icsDates) has(t time.Time, loc *time.Location) bool {
+	return d.instants[t.Unix()] || d.dates[t.In(loc).Format("20060102")]
+}
+
+func icsExcludedDates(event *icsComponent, zones *icsZones) (icsDates, error) {
+	excluded := icsDates{instants: make(map[int64]bool), dates: make(map[string]bool)}
+	for _, p := range event.All("EXDATE") {
+		for _, value := range strings.Split(p.Value, ",") {
+			exdate := icsProperty{Name: p.Name, Params: p.Params, Value: value}
+			if isICSDate(exdate) {
+				excluded.dates[value] = true
+				continue
+			}
+			t, _, err := zones.parse(exdate)
+			if err != nil {
+				return excluded, err
+			}
+			excluded.instants[t.Unix()] = true
+		}
+	}
+	return excluded, nil
+}
+
+// icsSpeakers reads the speakers from ICS_SPEAKERS_PROPERTY, or the ATTENDEEs, or the ORGANIZER
+func icsSpeakers(event *icsComponent) []Person {
+	var persons []Person
+	if p, ok := event.Get(strings.ToUpper(icsSpeakersProperty)); ok {
+		for _, name := range icsTextList(p.Value) {
+			if name = strings.TrimSpace(name); name != "" {
+				persons = append(persons, Person{Name: name})
+			}
+		}
+		return persons
+	}
+
+	for _, p := range event.All("ATTENDEE") {
+		if !strings.EqualFold(p.Params["ROLE"], "NON-PARTICIPANT") {
+			persons = append(persons, Person{Name: icsPersonName(p)})
+		}
+	}
+	if len(persons) == 0 {
+		if p, ok := event.Get("ORGANIZER"); ok {
+			persons = append(persons, Person{Name: icsPersonName(p)})
+		}
+	}
+	return persons
+}
+
+// icsPersonName is the common name (CN), or the address without "mailto:"
+func icsPersonName(p icsProperty) string {
+	if cn := p.Params["CN"]; cn != "" {
+		return cn
+	}
+	value := p.Value
+	if strings.HasPrefix(strings.ToLower(value), "mailto:") {
+		value = value[len("mailto:"):]
+	}
+	return value
+}
+
+// parseICSComponents parses the content lines of an iCalendar file into its VCALENDAR
+func parseICSComponents(body []byte) (*icsComponent, error) {
+	var root *icsComponent
+	var stack []*icsComponent
+
+	for n, line := range unfoldICSLines(string(body)) {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		prop, err := parseICSLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("error on content line %v: %v", n+1, err)
+		}
+
+		switch prop.Name {
+		case "BEGIN":
+			component := &icsComponent{Name: strings.ToUpper(prop.Value)}
+			if len(stack) > 0 {
+				parent := stack[len(stack)-1]
+				parent.Children = append(parent.Children, component)
+			} else if root == nil {
+				root = component
+			} else {
+				return nil, fmt.Errorf("error on content line %v: only one VCALENDAR is supported", n+1)
+			}
+			stack = append(stack, component)
+		case "END":
+			if len(stack) == 0 || stack[len(stack)-1].Name != strings.ToUpper(prop.Value) {
+				return nil, fmt.Errorf("error on content line %v: unexpected END:%v", n+1, prop.Value)
+			}
+			stack = stack[:len(stack)-1]
+		default:
+			if len(stack) == 0 {
+				return nil, fmt.Errorf("error on content line %v: %v is outside of a component", n+1, prop.Name)
+			}
+			component := stack[len(stack)-1]
+			component.Properties = append(component.Properties, prop)
+		}
+	}
+
+	if root == nil || root.Name != "VCALENDAR" {
+		return nil, fmt.Errorf("error: no VCALENDAR found")
+	}
+	if len(stack) > 0 {
+		return nil, fmt.Errorf("error: missing END:%v", stack[len(stack)-1].Name)
+	}
+	return root, nil
+}
+
+// unfoldICSLines splits the content lines, joining the folded ones (continued after a space or tab)
+func unfoldICSLines(body string) []string {
+	body = strings.TrimPrefix(body, "\ufeff")
+	var lines []string
+	for _, line := range strings.Split(strings.Replace(body, "\r\n", "\n", -1), "\n") {
+		if len(lines) > 0 && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// parseICSLine parses NAME;PARAM=VALUE;PARAM="QUOTED:VALUE":VALUE
+func parseICSLine(line string) (icsProperty, error) {
+	inQuotes := false
+	nameEnd, valueStart := -1, -1
+	for i := 0; i < len(line) && valueStart < 0; i++ {
+		switch c := line[i]; {
+		case c == '"':
+			inQuotes = !inQuotes
+		case inQuotes:
+		case c == ';' && nameEnd < 0:
+			nameEnd = i
+		case c == ':':
+			if nameEnd < 0 {
+				nameEnd = i
+			}
+			valueStart = i + 1
+		}
+	}
+	if valueStart < 0 || nameEnd == 0 {
+		return icsProperty{}, fmt.Errorf("invalid content line %q", line)
+	}
+
+	prop := icsProperty{
+		Name:   strings.ToUpper(line[:nameEnd]),
+		Params: make(map[string]string),
+		Value:  line[valueStart:],
+	}
+	for _, param := range splitICSUnquoted(line[nameEnd:valueStart-1], ';') {
+		if param == "" {
+			continue
+		}
+		eq := strings.Index(param, "=")
+		if eq < 0 {
+			return icsProperty{}, fmt.Errorf("invalid parameter %q", param)
+		}
+		prop.Params[strings.ToUpper(param[:eq])] = strings.Trim(param[eq+1:], `"`)
+	}
+	return prop, nil
+}
+
+func splitICSUnquoted(s string, sep byte) []string {
+	var parts []string
+	inQuotes, start := false, 0
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '"':
+			inQuotes = !inQuotes
+		case s[i] == sep && !inQuotes:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// icsText unescapes a TEXT value
+func icsText(value string) string {
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		if value[i] != '\\' || i == len(value)-1 {
+			b.WriteByte(value[i])
+			continue
+		}
+		i++
+		switch value[i] {
+		case 'n', 'N':
+			b.WriteByte('\n')
+		default: // \\ \; \,
+			b.WriteByte(value[i])
+		}
+	}
+	return b.String()
+}
+
+// icsTextList splits a TEXT list on the unescaped commas
+func icsTextList(value string) []string {
+	var items []string
+	start := 0
+	for i := 0; i < len(value); i++ {
+		switch value[i] {
+		case '\\':
+			i++
+		case ',':
+			items = append(items, icsText(value[start:i]))
+			start = i + 1
+		}
+	}
+	return append(items, icsText(value[start:]))
+}
+
+func isICSDate(p icsProperty) bool {
+	return strings.EqualFold(p.Params["VALUE"], "DATE") || len(p.Value) == len("20060102")
+}
+
+// parseICSDuration parses a DURATION, e.g. PT1H30M, P1D or -PT15M
+func parseICSDuration(value string) (time.Duration, error) {
+	invalid := fmt.Errorf("error: invalid duration %q", value)
+
+	s, sign := value, time.Duration(1)
+	switch {
+	case strings.HasPrefix(s, "-"):
+		s, sign = s[1:], -1
+	case strings.HasPrefix(s, "+"):
+		s = s[1:]
+	}
+	if !strings.HasPrefix(s, "P") || len(s) < 3 {
+		return 0, invalid
+	}
+
+	units := map[byte]time.Duration{'W': 7 * 24 * time.Hour, 'D': 24 * time.Hour, 'H': time.Hour, 'M': time.Minute, 'S': time.Second}
+	var duration time.Duration
+	number := -1
+	for i := 1; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == 'T':
+			continue
+		case c >= '0' && c <= '9':
+			if number < 0 {
+				number = 0
+			}
+			number = number*10 + int(c-'0')
+		case units[c] != 0 && number >= 0:
+			duration += time.Duration(number) * units[c]
+			number = -1
+		default:
+			return 0, invalid
+		}
+	}
+	if number >= 0 {
+		return 0, invalid
+	}
+	return sign * duration, nil
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+const scheduleTestICS = `BEGIN:VCALENDAR
+VERSION:2.0
+PRODID:-//Test//EN
+X-WR-CALNAME:Satellite events
+X-WR-TIMEZONE:Europe/Lisbon
+BEGIN:VTIMEZONE
+TZID:W. Europe Standard Time
+BEGIN:STANDARD
+DTSTART:16010101T030000
+TZOFFSETFROM:+0200
+TZOFFSETTO:+0100
+RRULE:FREQ=YEARLY;BYDAY=-1SU;BYMONTH=10
+END:STANDARD
+BEGIN:DAYLIGHT
+DTSTART:16010101T020000
+TZOFFSETFROM:+0100
+TZOFFSETTO:+0200
+RRULE:FREQ=YEARLY;BYDAY=-1SU;BYMONTH=3
+END:DAYLIGHT
+END:VTIMEZONE
+BEGIN:VEVENT
+UID:workshop@example.com
+DTSTART;TZID=Europe/Lisbon:20191010T100000
+DTEND;TZID=Europe/Lisbon:20191010T113000
+SUMMARY:Packaging workshop\, part 1
+LOCATION:Workshop Room
+X-SPEAKERS:PersonName1,PersonName2
+DESCRIPTION:A long
+  description
+END:VEVENT
+BEGIN:VEVENT
+UID:meetup@example.com
+DTSTART:20191010T130000Z
+DURATION:PT45M
+SUMMARY:Meetup
+LOCATION:Hall
+ORGANIZER;CN="Doe; Jane":mailto:jane@example.com
+END:VEVENT
+BEGIN:VEVENT
+UID:hacking@example.com
+DTSTART;TZID=W. Europe Standard Time:20191017T100000
+DTEND;TZID=W. Europe Standard Time:20191017T120000
+RRULE:FREQ=WEEKLY;COUNT=4
+EXDATE;TZID=W. Europe Standard Time:20191031T100000
+SUMMARY:Hacking
+LOCATION:Hall
+ATTENDEE;CN=PersonName3;ROLE=CHAIR:mailto:p3@example.com
+ATTENDEE;ROLE=NON-PARTICIPANT:mailto:room@example.com
+END:VEVENT
+BEGIN:VEVENT
+UID:hacking@example.com
+RECURRENCE-ID;TZID=W. Europe Standard Time:20191024T100000
+DTSTART;TZID=W. Europe Standard Time:20191024T140000
+DTEND;TZID=W. Europe Standard Time:20191024T160000
+SUMMARY:Hacking (afternoon)
+LOCATION:Hall
+END:VEVENT
+BEGIN:VEVENT
+UID:cancelled@example.com
+DTSTART:20191010T150000Z
+SUMMARY:Cancelled
+LOCATION:Hall
+STATUS:CANCELLED
+END:VEVENT
+BEGIN:VEVENT
+UID:allday@example.com
+DTSTART;VALUE=DATE:20191010
+SUMMARY:Hackerspace open
+LOCATION:Hall
+END:VEVENT
+END:VCALENDAR
+`
+
+func TestParseICSSchedule(t *testing.T) {
+	schedule, err := parseICSSchedule([]byte(strings.Replace(scheduleTestICS, "\n", "\r\n", -1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := schedule.Conference; c.Title != "Satellite events" || c.TimeZoneName != "Europe/Lisbon" || c.Days != 4 || c.Start != "2019-10-10" || c.End != "2019-11-07" {
+		t.Errorf("Unexpected conference: %+v", c)
+	}
+
+	type expectedEvent struct {
+		day, room, guid, date, title, duration, speakers string
+	}
+	var got []expectedEvent
+	for _, day := range schedule.Days {
+		for _, room := range day.Rooms {
+			for _, e := range room.Events {
+				var speakers []string
+				for _, p := range e.Persons {
+					speakers = append(speakers, p.Name)
+				}
+				got = append(got, expectedEvent{day.Date, room.Name, e.GUID, e.Date, e.Title, e.Duration, strings.Join(speakers, "|")})
+			}
+		}
+	}
+
+	// W. Europe Standard Time is +02:00 until the last sunday of October (Lisbon is +01:00, then +00:00)
+	expected := []expectedEvent{
+		{"2019-10-10", "Workshop Room", "workshop@example.com", "2019-10-10T10:00:00+01:00", "Packaging workshop, part 1", "01:30", "PersonName1|PersonName2"},
+		{"2019-10-10", "Hall", "meetup@example.com", "2019-10-10T14:00:00+01:00", "Meetup", "00:45", "Doe; Jane"},
+		{"2019-10-17", "Hall", "hacking@example.com/20191017T080000Z", "2019-10-17T09:00:00+01:00", "Hacking", "02:00", "PersonName3"},
+		{"2019-10-24", "Hall", "hacking@example.com/20191024T080000Z", "2019-10-24T13:00:00+01:00", "Hacking (afternoon)", "02:00", ""},
+		{"2019-11-07", "Hall", "hacking@example.com/20191107T090000Z", "2019-11-07T09:00:00+00:00", "Hacking", "02:00", "PersonName3"},
+	}
+	if len(got) != len(expected) {
+		t.Fatalf("Unexpected events.\nGot: %+v\nExpected: %+v", got, expected)
+	}
+	for i := range expected {
+		if got[i] != expected[i] {
+			t.Errorf("Unexpected event %v.\nGot:      %+v\nExpected: %+v", i, got[i], expected[i])
+		}
+	}
+
+	if desc := schedule.Days[0].Rooms[0].Events[0].Description; desc != "A long description" {
+		t.Errorf("Folded lines should be joined. Got: %q", desc)
+	}
+	if _, err := parseICSSchedule([]byte("BEGIN:VCALENDAR\nBEGIN:VEVENT\nSUMMARY:Truncated\n")); err == nil {
+		t.Error("A truncated calendar should fail")
+	}
+}
+
+func TestICSRecurrenceHorizon(t *testing.T) {
+	calendar := func(stamp string) []byte {
+		return []byte("BEGIN:VCALENDAR\nBEGIN:VEVENT\nUID:meetup@example.com\n" + stamp +
+			"DTSTART:20191010T100000Z\nDURATION:PT1H\nRRULE:FREQ=WEEKLY\nSUMMARY:Meetup\nLOCATION:Hall\nEND:VEVENT\nEND:VCALENDAR\n")
+	}
+	for _, tc := range []struct {
+		stamp, lastDay string
+		days           int
+	}{
+		{"", "2020-10-08", 53},                           // a year after DTSTART
+		{"DTSTAMP:20200601T000000Z\n", "2021-05-27", 86}, // a year after the export
+	} {
+		schedule, err := parseICSSchedule(calendar(tc.stamp))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n := len(schedule.Days); n != tc.days || schedule.Days[n-1].Date != tc.lastDay {
+			t.Errorf("Unexpected occurrences with %q. Got %v days, until %v", tc.stamp, n, schedule.Days[n-1].Date)
+		}
+	}
+}
+
+func TestICSRecurrenceExpand(t *testing.T) {
+	date := func(s string) time.Time {
+		d, _ := time.Parse("20060102T150405", s)
+		return d
+	}
+	for _, tc := range []struct {
+		rule     string
+		dtstart  string
+		expected []string
+	}{
+		{"FREQ=DAILY;INTERVAL=2;COUNT=3", "20191010T100000", []string{"20191010T100000", "20191012T100000", "20191014T100000"}},
+		{"FREQ=WEEKLY;BYDAY=TU,TH;UNTIL=20191017T235959Z", "20191010T100000", []string{"20191010T100000", "20191015T100000", "20191017T100000"}},
+		{"FREQ=MONTHLY;BYDAY=-1FR;COUNT=3", "20191025T180000", []string{"20191025T180000", "20191129T180000", "20191227T180000"}},
+		{"FREQ=MONTHLY;BYMONTHDAY=31;COUNT=3", "20191031T180000", []string{"20191031T180000", "20191231T180000", "20200131T180000"}},
+		{"FREQ=YEARLY;BYMONTH=3;BYDAY=-1SU;COUNT=2", "20190331T010000", []string{"20190331T010000", "20200329T010000"}},
+	} {
+		rule, err := parseICSRecurrence(tc.rule, nil, &icsZones{})
+		if err != nil {
+			t.Errorf("%v: %v", tc.rule, err)
+			continue
+		}
+		var got []string
+		for _, o := range rule.Expand(date(tc.dtstart), utcICSZone, date(tc.dtstart).AddDate(5, 0, 0)) {
+			got = append(got, o.Format("20060102T150405"))
+		}
+		if strings.Join(got, ",") != strings.Join(tc.expected, ",") {
+			t.Errorf("%v: got %v, expected %v", tc.rule, got, tc.expected)
+		}
+	}
+
+	if _, err := parseICSRecurrence("FREQ=HOURLY", nil, &icsZones{}); err == nil {
+		t.Error("FREQ=HOURLY should not be supported")
+	}
+}
+
+func TestParseICSDuration(t *testing.T) {
+	for value, expected := range map[string]time.Duration{
+		"PT1H30M": 90 * time.Minute,
+		"P1D":     24 * time.Hour,
+		"P1DT1H":  25 * time.Hour,
+		"-PT15M":  -15 * time.Minute,
+		"P1W":     7 * 24 * time.Hour,
+	} {
+		if d, err := parseICSDuration(value); err != nil || d != expected {
+			t.Errorf("%v: got %v (%v), expected %v", value, d, err, expected)
+		}
+	}
+	for _, value := range []string{"", "P", "PT", "1H", "PT1X", "PT1H30"} {
+		if _, err := parseICSDuration(value); err == nil {
+			t.Errorf("%q should fail", value)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// jsonScheduleFile is the schedule.json export of pretalx/frab
+type jsonScheduleFile struct {
+	Schedule struct {
+		Version    string         `json:"version"`
+		Conference jsonConference `json:"conference"`
+	} `json:"schedule"`
+}
+
+type jsonConference struct {
+	Acronym      string    `json:"acronym"`
+	Title        string    `json:"title"`
+	Start        string    `json:"start"`
+	End          string    `json:"end"`
+	DaysCount    int       `json:"daysCount"`
+	TimeZoneName string    `json:"time_zone_name"`
+	Days         []jsonDay `json:"days"`
+}
+
+type jsonDay struct {
+	Date     string    `json:"date"`
+	DayStart string    `json:"day_start"`
+	DayEnd   string    `json:"day_end"`
+	Rooms    jsonRooms `json:"rooms"`
+}
+
+// jsonRooms is a JSON object of room name to its events. The rooms order is kept.
+type jsonRooms []Room
+
+type jsonEvent struct {
+	ID          int          `json:"id"`
+	GUID        string       `json:"guid"`
+	Date        string       `json:"date"`
+	Title       string       `json:"title"`
+	Start       string       `json:"start"`
+	Duration    string       `json:"duration"`
+	URL         string       `json:"url"`
+	Slug        string       `json:"slug"`
+	Type        string       `json:"type"`
+	Abstract    string       `json:"abstract"`
+	Description string       `json:"description"`
+	Persons     []jsonPerson `json:"persons"`
+}
+
+type jsonPerson struct {
+	ID         int    `json:"id"`
+	Name       string `json:"name"`
+	PublicName string `json:"public_name"` // frab/pretalx use public_name
+}
+
+// UnmarshalJSON decodes the rooms object keeping the order of the rooms
+func (rooms *jsonRooms) UnmarshalJSON(data []byte) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	if token, err := decoder.Token(); err != nil || token != json.Delim('{') {
+		return fmt.Errorf("rooms should be an object of room names to events")
+	}
+
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+		var events []jsonEvent
+		if err := decoder.Decode(&events); err != nil {
+			return err
+		}
+
+		room := Room{Name: token.(string)}
+		for _, e := range events {
+			room.Events = append(room.Events, e.toEvent())
+		}
+		*rooms = append(*rooms, room)
+	}
+	return nil
+}
+
+func (e jsonEvent) toEvent() Event {
+	event := Event{
+		ID:          e.ID,
+		GUID:        e.GUID,
+		Date:        e.Date,
+		Title:       e.Title,
+		Start:       e.Start,
+		Duration:    e.Duration,
+		URL:         e.URL,
+		Slug:        e.Slug,
+		Type:        e.Type,
+		Abstract:    e.Abstract,
+		Description: e.Description,
+	}
+	for _, p := range e.Persons {
+		name := p.PublicName
+		if name == "" {
+			name = p.Name
+		}
+		event.Persons = append(event.Persons, Person{ID: p.ID, Name: strings.TrimSpace(name)})
+	}
+	return event
+}
+
+// parseJSONSchedule parses the schedule.json export into the same model as the XML one
+func parseJSONSchedule(body []byte) (Schedule, error) {
+	var file jsonScheduleFile
+	if err := json.Unmarshal(body, &file); err != nil {
+		return Schedule{}, err
+	}
+
+	c := file.Schedule.Conference
+	schedule := Schedule{
+		Version: file.Schedule.Version,
+		Conference: Conference{
+			Acronym:      c.Acronym,
+			Title:        c.Title,
+			Start:        c.Start,
+			End:          c.End,
+			Days:         c.DaysCount,
+			TimeZoneName: c.TimeZoneName,
+		},
+	}
+	for _, d := range c.Days {
+		schedule.Days = append(schedule.Days, Day{
+			Date:  d.Date,
+			Start: d.DayStart,
+			End:   d.DayEnd,
+			Rooms: d.Rooms,
+		})
+	}
+	return schedule, nil
+}
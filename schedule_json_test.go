@@ -0,0 +1,101 @@
+package main
+
+import (
+	"testing"
+)
+
+const scheduleTestJSON = `{
+  "schedule": {
+    "version": "0.13",
+    "conference": {
+      "acronym": "eu2019",
+      "title": "Conference Title",
+      "start": "2019-10-10",
+      "end": "2019-10-13",
+      "daysCount": 4,
+      "time_zone_name": "Europe/Lisbon",
+      "days": [
+        {
+          "index": 1,
+          "date": "2019-10-10",
+          "day_start": "2019-10-10T04:00:00+01:00",
+          "day_end": "2019-10-11T03:59:00+01:00",
+          "rooms": {
+            "Room2": [
+              {
+                "id": 7, "guid": "abc7", "date": "2019-10-10T10:00:00+01:00",
+                "title": "Event7", "start": "10:00", "duration": "00:30",
+                "persons": [{"id": 1, "public_name": "PersonName1"}, {"id": 2, "name": "PersonName2"}]
+              }
+            ],
+            "Room1": []
+          }
+        }
+      ]
+    }
+  }
+}`
+
+func TestParseJSONSchedule(t *testing.T) {
+	schedule, err := parseJSONSchedule([]byte(scheduleTestJSON))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := schedule.Conference
+	if c.Title != "Conference Title" || c.Days != 4 || c.TimeZoneName != "Europe/Lisbon" {
+		t.Errorf("Unexpected conference: %+v", c)
+	}
+	if len(schedule.Days) != 1 || schedule.Days[0].Start != "2019-10-10T04:00:00+01:00" {
+		t.Fatalf("Unexpected days: %+v", schedule.Days)
+	}
+
+	rooms := schedule.Days[0].Rooms
+	if len(rooms) != 2 || rooms[0].Name != "Room2" || rooms[1].Name != "Room1" {
+		t.Fatalf("Rooms should keep the JSON order. Got: %+v", rooms)
+	}
+	event := rooms[0].Events[0]
+	if event.GUID != "abc7" || event.Title != "Event7" || event.Duration != "00:30" {
+		t.Errorf("Unexpected event: %+v", event)
+	}
+	if len(event.Persons) != 2 || event.Persons[0].Name != "PersonName1" || event.Persons[1].Name != "PersonName2" {
+		t.Errorf("Unexpected persons: %+v", event.Persons)
+	}
+
+	if _, err := parseJSONSchedule([]byte(`{"schedule": {"conference": {"days": [{"rooms": []}]}}}`)); err == nil {
+		t.Error("Rooms as a list should fail")
+	}
+}
+
+func TestDetectScheduleFormat(t *testing.T) {
+	for _, tc := range []struct {
+		body     string
+		hints    []string
+		expected string
+	}{
+		{`{}`, []string{"application/json; charset=utf-8"}, "json"},
+		{`{}`, []string{"text/xml"}, "xml"},
+		{`<schedule/>`, []string{"application/vnd.frab+json"}, "json"},
+		{`<schedule/>`, []string{"https://pretalx.com/eu2019/schedule/export/schedule.json?lang=en"}, "json"},
+		{`{}`, []string{"text/plain", "/tmp/schedule.xml"}, "xml"},
+		{`  {"schedule": {}}`, []string{"https://example.com/schedule"}, "json"},
+		{`<schedule/>`, nil, "xml"},
+		{"BEGIN:VCALENDAR\r\nEND:VCALENDAR", nil, "ics"},
+		{`{}`, []string{"text/calendar; charset=utf-8"}, "ics"},
+		{`{}`, []string{"webcal.example.com/satellite.ics"}, "ics"},
+		{"date,start", []string{"text/csv; charset=utf-8"}, "csv"},
+		{"date,start", []string{"text/plain", "https://example.com/workshops.CSV"}, "csv"},
+	} {
+		if format := detectScheduleFormat([]byte(tc.body), tc.hints...); format != tc.expected {
+			t.Errorf("Unexpected format for %q (%v). Got %v, expected %v", tc.body, tc.hints, format, tc.expected)
+		}
+	}
+
+	if _, err := parseScheduleBody([]byte(scheduleTestJSON), "yaml"); err == nil {
+		t.Error("Unknown formats should fail")
+	}
+	csv := "date,start,duration,room,title,speakers\n2019-10-10,10:00,45,Workshop Room,Event1,PersonName1\n"
+	if schedule, err := parseScheduleBody([]byte(csv), "csv"); err != nil || schedule.Days[0].Rooms[0].Events[0].Title != "Event1" {
+		t.Errorf("Unexpected CSV schedule: %+v, %v", schedule, err)
+	}
+}
@@ -0,0 +1,316 @@
+package main
+
+import (
+	"container/heap"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Job is a pending room update, waiting for its time to be dispatched
+type Job struct {
+	Key    string    `json:"key"` // unique per room and event (see jobKey)
+	RoomID int       `json:"room_id"`
+	At     time.Time `json:"at"`
+	Start  time.Time `json:"start"` // start of the event shown (zero for manual updates)
+	Info   RoomInfo  `json:"info"`
+
+	Attempt  int    `json:"attempt"`  // number of times it was already dispatched
+	Seq      uint64 `json:"seq"`      // dispatch sequence on its room (see RoomStates.Begin)
+	Override bool   `json:"override"` // manual update (see AdminAPI), sent even if the room is overridden
+
+	publisher Publisher // when set, overrides the configured publisher (e.g. on retries)
+}
+
+// jobKey identifies the update of a room for a given event.
+// Scheduling a job with an existing key replaces the pending one.
+func jobKey(roomID int, event Event) string {
+	if event.GUID == "" {
+		// XXX: extra (hand-written) schedules may not have GUIDs
+		return strconv.Itoa(roomID) + "/" + event.Date + "/" + event.Title
+	}
+	return strconv.Itoa(roomID) + "/" + event.GUID
+}
+
+// jobQueue is a min-heap of jobs ordered by time (see container/heap)
+type jobQueue []*queuedJob
+
+type queuedJob struct {
+	job   Job
+	seq   uint64 // insertion order, to keep jobs with the same time stable
+	index int
+}
+
+func (q jobQueue) Len() int { return len(q) }
+
+func (q jobQueue) Less(i, j int) bool {
+	if q[i].job.At.Equal(q[j].job.At) {
+		return q[i].seq < q[j].seq
+	}
+	return q[i].job.At.Before(q[j].job.At)
+}
+
+func (q jobQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *jobQueue) Push(x interface{}) {
+	item := x.(*queuedJob)
+	item.index = len(*q)
+	*q = append(*q, item)
+}
+
+func (q *jobQueue) Pop() interface{} {
+	old := *q
+	n := len(old)
+	item := old[n-1]
+	old[n-1] = nil
+	item.index = -1
+	*q = old[:n-1]
+	return item
+}
+
+// Scheduler keeps all pending room updates in a priority queue,
+// and dispatches them from a single timer loop.
+type Scheduler struct {
+	mu       sync.Mutex
+	queue    jobQueue
+	byKey    map[string]*queuedJob
+	seq      uint64
+	dispatch func(Job)
+	Clock    Clock // the time of the jobs, the bot clock by default
+
+	wake     chan struct{}
+	stop     chan struct{}
+	idle     *sync.Cond // signaled when the queue and in-flight jobs drain
+	inFlight int
+	rooms    map[int][]Job // due jobs of the rooms being dispatched, in order
+}
+
+// NewScheduler creates a Scheduler that calls dispatch for each due job.
+// Run must be called for jobs to be dispatched.
+func NewScheduler(dispatch func(Job)) *Scheduler {
+	s := &Scheduler{
+		byKey:    make(map[string]*queuedJob),
+		rooms:    make(map[int][]Job),
+		dispatch: dispatch,
+		Clock:    clock,
+		wake:     make(chan struct{}, 1),
+		stop:     make(chan struct{}),
+	}
+	s.idle = sync.NewCond(&s.mu)
+	return s
+}
+
+// Schedule adds a job to the queue. A pending job with the same key is replaced.
+func (s *Scheduler) Schedule(job Job) {
+	s.mu.Lock()
+	if item, ok := s.byKey[job.Key]; ok {
+		item.job = job
+		heap.Fix(&s.queue, item.index)
+	} else {
+		s.seq++
+		item := &queuedJob{job: job, seq: s.seq}
+		heap.Push(&s.queue, item)
+		s.byKey[job.Key] = item
+	}
+	s.mu.Unlock()
+	s.notify()
+}
+
+// ScheduleIfAbsent adds a job to the queue, unless there is a pending job with the same key.
+// Returns false if the job was not added.
+func (s *Scheduler) ScheduleIfAbsent(job Job) bool {
+	s.mu.Lock()
+	if _, ok := s.byKey[job.Key]; ok {
+		s.mu.Unlock()
+		return false
+	}
+	s.seq++
+	item := &queuedJob{job: job, seq: s.seq}
+	heap.Push(&s.queue, item)
+	s.byKey[job.Key] = item
+	s.mu.Unlock()
+	s.notify()
+	return true
+}
+
+// Cancel removes a pending job. Returns false if there is no such job.
+func (s *Scheduler) Cancel(key string) bool {
+	s.mu.Lock()
+	item, ok := s.byKey[key]
+	if ok {
+		heap.Remove(&s.queue, item.index)
+		delete(s.byKey, key)
+		s.signalIfIdle()
+	}
+	s.mu.Unlock()
+	if ok {
+		s.notify()
+	}
+	return ok
+}
+
+// Reschedule moves a pending job to a new time. Returns false if there is no such job.
+func (s *Scheduler) Reschedule(key string, at time.Time) bool {
+	s.mu.Lock()
+	item, ok := s.byKey[key]
+	if ok {
+		item.job.At = at
+		heap.Fix(&s.queue, item.index)
+	}
+	s.mu.Unlock()
+	if ok {
+		s.notify()
+	}
+	return ok
+}
+
+// Get returns the pending job with the given key
+func (s *Scheduler) Get(key string) (Job, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if item, ok := s.byKey[key]; ok {
+		return item.job, true
+	}
+	return Job{}, false
+}
+
+// Pending returns a copy of all pending jobs, ordered by time
+func (s *Scheduler) Pending() []Job {
+	s.mu.Lock()
+	items := make(jobQueue, len(s.queue))
+	copy(items, s.queue)
+	s.mu.Unlock()
+
+	sort.Slice(items, func(i, j int) bool { return items.Less(i, j) })
+	jobs := make([]Job, len(items))
+	for i, item := range items {
+		jobs[i] = item.job
+	}
+	return jobs
+}
+
+// Run is the timer loop. It blocks until Stop is called.
+func (s *Scheduler) Run() {
+	timer := s.Clock.NewTimer(time.Hour)
+	defer timer.Stop()
+
+	for {
+		for _, job := range s.popDue(s.Clock.Now()) {
+			s.start(job)
+		}
+
+		if !timer.Stop() {
+			select {
+			case <-timer.C():
+			default:
+			}
+		}
+		if next, ok := s.nextTime(); ok {
+			timer.Reset(next.Sub(s.Clock.Now()))
+		}
+
+		select {
+		case <-timer.C():
+		case <-s.wake:
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// Stop ends the timer loop. Pending jobs are kept.
+func (s *Scheduler) Stop() {
+	close(s.stop)
+}
+
+// Wait blocks until there are no pending nor running jobs
+func (s *Scheduler) Wait() {
+	s.mu.Lock()
+	for len(s.queue) > 0 || s.inFlight > 0 {
+		s.idle.Wait()
+	}
+	s.mu.Unlock()
+}
+
+// start dispatches a due job. The jobs of a room are dispatched one at a time, in order,
+// so an older update is never sent after a newer one.
+func (s *Scheduler) start(job Job) {
+	s.mu.Lock()
+	queue, running := s.rooms[job.RoomID]
+	s.rooms[job.RoomID] = append(queue, job)
+	s.mu.Unlock()
+	if !running {
+		go s.runRoom(job.RoomID)
+	}
+}
+
+// runRoom dispatches the due jobs of a room until there are none
+func (s *Scheduler) runRoom(roomID int) {
+	for {
+		s.mu.Lock()
+		queue := s.rooms[roomID]
+		if len(queue) == 0 {
+			delete(s.rooms, roomID)
+			s.mu.Unlock()
+			return
+		}
+		job := queue[0]
+		s.rooms[roomID] = queue[1:]
+		s.mu.Unlock()
+
+		s.run(job)
+	}
+}
+
+func (s *Scheduler) run(job Job) {
+	s.dispatch(job)
+
+	s.mu.Lock()
+	s.inFlight--
+	s.signalIfIdle()
+	s.mu.Unlock()
+}
+
+// popDue removes and returns all jobs due at the given time
+func (s *Scheduler) popDue(now time.Time) []Job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var due []Job
+	for len(s.queue) > 0 && !s.queue[0].job.At.After(now) {
+		item := heap.Pop(&s.queue).(*queuedJob)
+		delete(s.byKey, item.job.Key)
+		due = append(due, item.job)
+	}
+	s.inFlight += len(due)
+	return due
+}
+
+func (s *Scheduler) nextTime() (time.Time, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.queue) == 0 {
+		return time.Time{}, false
+	}
+	return s.queue[0].job.At, true
+}
+
+// signalIfIdle must be called with s.mu held
+func (s *Scheduler) signalIfIdle() {
+	if len(s.queue) == 0 && s.inFlight == 0 {
+		s.idle.Broadcast()
+	}
+}
+
+// notify wakes up the timer loop, so it can recompute the next timer
+func (s *Scheduler) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSchedulerPendingOrder(t *testing.T) {
+	s := NewScheduler(func(Job) {})
+	now := time.Now()
+
+	s.Schedule(Job{Key: "c", At: now.Add(3 * time.Hour)})
+	s.Schedule(Job{Key: "a", At: now.Add(1 * time.Hour)})
+	s.Schedule(Job{Key: "b", At: now.Add(2 * time.Hour)})
+
+	pending := s.Pending()
+	if len(pending) != 3 {
+		t.Fatal("Unexpected number of pending jobs:", len(pending))
+	}
+	for i, key := range []string{"a", "b", "c"} {
+		if pending[i].Key != key {
+			t.Errorf("Unexpected job at position %v. Got: %v Expected: %v", i, pending[i].Key, key)
+		}
+	}
+}
+
+func TestSchedulerReplaceCancelReschedule(t *testing.T) {
+	s := NewScheduler(func(Job) {})
+	now := time.Now()
+
+	s.Schedule(Job{Key: "a", At: now.Add(1 * time.Hour), Info: RoomInfo{CurrentTitle: "old"}})
+	s.Schedule(Job{Key: "b", At: now.Add(2 * time.Hour)})
+	s.Schedule(Job{Key: "a", At: now.Add(3 * time.Hour), Info: RoomInfo{CurrentTitle: "new"}})
+
+	pending := s.Pending()
+	if len(pending) != 2 || pending[0].Key != "b" || pending[1].Info.CurrentTitle != "new" {
+		t.Errorf("Scheduling an existing key should replace the job. Got: %v", pending)
+	}
+
+	if !s.Reschedule("a", now) {
+		t.Error("Reschedule of a pending job should succeed")
+	}
+	if pending = s.Pending(); pending[0].Key != "a" {
+		t.Errorf("Rescheduled job should be first. Got: %v", pending[0].Key)
+	}
+
+	if !s.Cancel("a") {
+		t.Error("Cancel of a pending job should succeed")
+	}
+	if s.Cancel("a") || s.Reschedule("a", now) {
+		t.Error("Cancel/Reschedule of an unknown job should fail")
+	}
+	if _, ok := s.Get("b"); !ok || len(s.Pending()) != 1 {
+		t.Errorf("Only job b should be pending. Got: %v", s.Pending())
+	}
+}
+
+func TestSchedulerRun(t *testing.T) {
+	var mu sync.Mutex
+	var dispatched []string
+	s := NewScheduler(func(job Job) {
+		mu.Lock()
+		dispatched = append(dispatched, job.Key)
+		mu.Unlock()
+	})
+	go s.Run()
+	defer s.Stop()
+
+	now := time.Now()
+	s.Schedule(Job{Key: "second", At: now.Add(60 * time.Millisecond)})
+	s.Schedule(Job{Key: "first", At: now.Add(10 * time.Millisecond)})
+	s.Schedule(Job{Key: "cancelled", At: now.Add(30 * time.Millisecond)})
+	s.Cancel("cancelled")
+
+	s.Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(dispatched) != 2 || dispatched[0] != "first" || dispatched[1] != "second" {
+		t.Errorf("Unexpected dispatched jobs: %v", dispatched)
+	}
+}
+
+func TestSchedulerRunRoomInOrder(t *testing.T) {
+	var mu sync.Mutex
+	var dispatched []string
+	running := 0
+	s := NewScheduler(func(job Job) {
+		mu.Lock()
+		running++
+		if running > 1 {
+			t.Errorf("Job %v was dispatched while another job of the room was running", job.Key)
+		}
+		mu.Unlock()
+
+		if job.Key == "older" {
+			time.Sleep(20 * time.Millisecond) // e.g. a slow publisher
+		}
+
+		mu.Lock()
+		running--
+		dispatched = append(dispatched, job.Key)
+		mu.Unlock()
+	})
+
+	now := time.Now()
+	s.Schedule(Job{Key: "older", RoomID: 1, At: now})
+	s.Schedule(Job{Key: "newer", RoomID: 1, At: now})
+	go s.Run()
+	defer s.Stop()
+	s.Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(dispatched) != 2 || dispatched[0] != "older" || dispatched[1] != "newer" {
+		t.Errorf("The jobs of a room should be dispatched in order. Got: %v", dispatched)
+	}
+}
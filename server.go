@@ -0,0 +1,34 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"time"
+)
+
+var serverAddr = GetEnv("SERVER_ADDR", "") // e.g. ":8080". Empty disables the HTTP server
+
+// newServerMux routes the bot HTTP server
+func newServerMux() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.Handle("/rooms/", roomHub)
+	if adminToken != "" {
+		mux.Handle("/admin/", &AdminAPI{
+			Token:     adminToken,
+			Scheduler: scheduler,
+			States:    roomStates,
+			Shifts:    roomShifts,
+			Replan:    replanSchedule,
+			Location:  func() *time.Location { return venueLocation(getLoadedSchedule().Conference) },
+		})
+	}
+	return mux
+}
+
+// startServer runs the HTTP server on addr. It only returns on errors.
+func startServer(addr string) {
+	log.Printf("Serving HTTP on %v\n", addr)
+	if err := http.ListenAndServe(addr, newServerMux()); err != nil {
+		log.Println("ERROR: HTTP server stopped.", err)
+	}
+}
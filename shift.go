@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+const eventDateLayout = "2006-01-02T15:04:05-07:00" // Event.Date, as exported by pretalx
+
+// RoomShift delays (or advances, if negative) the events of a room, from a given time on.
+// Events starting at or after From are moved. An event running at From is extended.
+type RoomShift struct {
+	ID      int       `json:"id"`
+	RoomID  int       `json:"room_id"`
+	From    time.Time `json:"from"`
+	Minutes int       `json:"minutes"`
+}
+
+// ShiftStore keeps the shifts applied to the schedule, so they can be undone
+// and survive schedule reloads
+type ShiftStore struct {
+	mu     sync.Mutex
+	shifts []RoomShift
+	lastID int
+}
+
+var roomShifts = &ShiftStore{}
+
+// Add stores a new shift, returning it with its ID
+func (s *ShiftStore) Add(shift RoomShift) RoomShift {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastID++
+	shift.ID = s.lastID
+	s.shifts = append(s.shifts, shift)
+	return shift
+}
+
+// Undo removes the shift with the given ID. ID 0 removes the last shift.
+func (s *ShiftStore) Undo(id int) (RoomShift, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := len(s.shifts) - 1; i >= 0; i-- {
+		if id == 0 || s.shifts[i].ID == id {
+			shift := s.shifts[i]
+			s.shifts = append(s.shifts[:i], s.shifts[i+1:]...)
+			return shift, true
+		}
+	}
+	return RoomShift{}, false
+}
+
+// List returns the shifts, in the order they are applied
+func (s *ShiftStore) List() []RoomShift {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]RoomShift{}, s.shifts...)
+}
+
+// Apply returns a copy of the schedule with all shifts applied
+func (s *ShiftStore) Apply(schedule Schedule) Schedule {
+	shifts := s.List()
+	if len(shifts) == 0 {
+		return schedule
+	}
+
+	loc := venueLocation(schedule.Conference)
+	shifted := copySchedule(schedule)
+	for _, shift := range shifts {
+		for d := range shifted.Days {
+			for r := range shifted.Days[d].Rooms {
+				room := &shifted.Days[d].Rooms[r]
+				if room.ID != shift.RoomID {
+					continue
+				}
+				for e := range room.Events {
+					if err := shiftEvent(&room.Events[e], shift, loc); err != nil {
+						log.Printf("WARNING: Could not shift event %v: %v\n", room.Events[e].ID, err)
+					}
+				}
+			}
+		}
+	}
+	return shifted
+}
+
+// shiftEvent moves the event (both Date and Start) if it starts at or after shift.From,
+// or extends its Duration if it is running at shift.From
+func shiftEvent(event *Event, shift RoomShift, loc *time.Location) error {
+	by := time.Duration(shift.Minutes) * time.Minute
+
+	start, err := ParseEventTime(event.Date, loc)
+	if err != nil {
+		return err
+	}
+	duration, err := ParseCustomDuration(event.Duration)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case !start.Before(shift.From):
+		start = start.Add(by)
+		event.Date = start.Format(eventDateLayout)
+		event.Start = start.In(loc).Format("15:04")
+	case start.Add(duration).After(shift.From):
+		if duration += by; duration < 0 {
+			duration = 0
+		}
+		event.Duration = FormatCustomDuration(duration)
+	}
+	return nil
+}
+
+// FormatCustomDuration formats the duration as HH:MM (see ParseCustomDuration)
+func FormatCustomDuration(duration time.Duration) string {
+	minutes := int(duration / time.Minute)
+	return fmt.Sprintf("%02d:%02d", minutes/60, minutes%60)
+}
+
+func copySchedule(schedule Schedule) Schedule {
+	copied := schedule
+	copied.Days = make([]Day, len(schedule.Days))
+	for d, day := range schedule.Days {
+		copied.Days[d] = day
+		copied.Days[d].Rooms = make([]Room, len(day.Rooms))
+		for r, room := range day.Rooms {
+			copied.Days[d].Rooms[r] = room
+			copied.Days[d].Rooms[r].Events = append([]Event{}, room.Events...)
+		}
+	}
+	return copied
+}
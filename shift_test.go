@@ -0,0 +1,130 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func shiftTestSchedule() Schedule {
+	return Schedule{
+		Conference: Conference{TimeZoneName: "Europe/Lisbon"},
+		Days: []Day{
+			Day{
+				Rooms: []Room{
+					Room{
+						ID: 1,
+						Events: []Event{
+							Event{GUID: "a", Date: "2019-10-10T10:00:00+01:00", Start: "10:00", Duration: "00:45"},
+							Event{GUID: "b", Date: "2019-10-10T10:45:00+01:00", Start: "10:45", Duration: "00:30"},
+							Event{GUID: "c", Date: "2019-10-10T11:30:00+01:00", Start: "11:30", Duration: "00:30"},
+						},
+					},
+					Room{
+						ID: 2,
+						Events: []Event{
+							Event{GUID: "d", Date: "2019-10-10T11:30:00+01:00", Start: "11:30", Duration: "00:30"},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestShiftStoreApply(t *testing.T) {
+	schedule := shiftTestSchedule()
+	shifts := &ShiftStore{}
+	from, _ := time.Parse(eventDateLayout, "2019-10-10T10:30:00+01:00")
+	shifts.Add(RoomShift{RoomID: 1, From: from, Minutes: 10})
+
+	shifted := shifts.Apply(schedule)
+	events := shifted.Days[0].Rooms[0].Events
+
+	if events[0].Date != "2019-10-10T10:00:00+01:00" || events[0].Duration != "00:55" {
+		t.Errorf("The running event should be extended. Got: %v %v", events[0].Date, events[0].Duration)
+	}
+	if events[1].Date != "2019-10-10T10:55:00+01:00" || events[1].Start != "10:55" || events[1].Duration != "00:30" {
+		t.Errorf("Unexpected shifted event: %v %v %v", events[1].Date, events[1].Start, events[1].Duration)
+	}
+	if events[2].Start != "11:40" {
+		t.Errorf("Unexpected shifted event start: %v", events[2].Start)
+	}
+	if other := shifted.Days[0].Rooms[1].Events[0]; other.Start != "11:30" {
+		t.Errorf("Other rooms should not be shifted. Got: %v", other.Start)
+	}
+	if schedule.Days[0].Rooms[0].Events[1].Start != "10:45" {
+		t.Error("The original schedule should not be changed")
+	}
+
+	// undo
+	if _, ok := shifts.Undo(0); !ok {
+		t.Error("Undo of the last shift should succeed")
+	}
+	if events = shifts.Apply(schedule).Days[0].Rooms[0].Events; events[1].Start != "10:45" || events[0].Duration != "00:45" {
+		t.Errorf("Undone shift should not be applied. Got: %v %v", events[1].Start, events[0].Duration)
+	}
+	if _, ok := shifts.Undo(1); ok {
+		t.Error("Undo of an unknown shift should fail")
+	}
+}
+
+func TestFormatCustomDuration(t *testing.T) {
+	for str, expected := range map[string]time.Duration{"00:00": 0, "00:45": 45 * time.Minute, "02:05": 125 * time.Minute} {
+		if got := FormatCustomDuration(expected); got != str {
+			t.Errorf("Unexpected format for %v. Got: %v Expected: %v", expected, got, str)
+		}
+	}
+}
+
+func TestParseShiftFrom(t *testing.T) {
+	now := time.Date(2019, 10, 10, 9, 0, 0, 0, time.UTC)
+
+	if from, _ := parseShiftFrom("", now); !from.Equal(now) {
+		t.Errorf("Empty should be now. Got: %v", from)
+	}
+	if from, _ := parseShiftFrom("14:30", now); !from.Equal(time.Date(2019, 10, 10, 14, 30, 0, 0, time.UTC)) {
+		t.Errorf("HH:MM should be today. Got: %v", from)
+	}
+	if _, err := parseShiftFrom("tomorrow", now); err == nil {
+		t.Error("An error was expected")
+	}
+}
+
+func TestAdminAPIShift(t *testing.T) {
+	api := newTestAdminAPI()
+	api.Shifts = &ShiftStore{}
+	replans := 0
+	api.Replan = func() { replans++ }
+
+	rec := adminRequest(api, http.MethodPost, "/admin/rooms/1/shift", `{"minutes": 10, "from": "2019-10-10T10:30:00+01:00"}`)
+	if rec.Code != http.StatusCreated || replans != 1 {
+		t.Fatalf("Unexpected shift response: %v %v (replans: %v)", rec.Code, rec.Body, replans)
+	}
+	if shifts := api.Shifts.List(); len(shifts) != 1 || shifts[0].RoomID != 1 || shifts[0].Minutes != 10 {
+		t.Errorf("Unexpected shifts: %v", shifts)
+	}
+
+	// HH:MM is on the bot (simulated) day, in the venue timezone
+	lisbon, _ := time.LoadLocation("Europe/Lisbon")
+	api.Location = func() *time.Location { return lisbon }
+	api.Scheduler.Clock = NewSimulatedClock(time.Date(2019, 10, 10, 23, 30, 0, 0, time.UTC), 0)
+	rec = adminRequest(api, http.MethodPost, "/admin/rooms/1/shift", `{"minutes": 5, "from": "14:30"}`)
+	if shifts := api.Shifts.List(); rec.Code != http.StatusCreated || len(shifts) != 2 || !shifts[1].From.Equal(time.Date(2019, 10, 11, 14, 30, 0, 0, lisbon)) {
+		t.Errorf("Unexpected shift from HH:MM: %v %v", rec.Code, rec.Body)
+	}
+	if rec = adminRequest(api, http.MethodPost, "/admin/rooms/1/shift", `{"minutes": 5, "from": "tomorrow"}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 with an invalid from. Got: %v", rec.Code)
+	}
+
+	if rec = adminRequest(api, http.MethodPost, "/admin/rooms/1/shift", `{}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 without minutes. Got: %v", rec.Code)
+	}
+
+	if rec = adminRequest(api, http.MethodDelete, "/admin/shifts/last", ""); rec.Code != http.StatusOK || replans != 3 {
+		t.Errorf("Unexpected undo response: %v %v", rec.Code, rec.Body)
+	}
+	if rec = adminRequest(api, http.MethodDelete, "/admin/shifts/2", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 undoing an unknown shift. Got: %v", rec.Code)
+	}
+}
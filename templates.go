@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+var templatesFile = GetEnv("TEMPLATES_FILE", "") // RoomInfo and message templates (see TemplatesConfig). Empty keeps the defaults
+
+// roomInfoTemplates customize createRoomInfo (see loadTemplates). Empty keeps the default fields.
+var roomInfoTemplates RoomInfoTemplates
+
+// TemplatesConfig is the TEMPLATES_FILE. Templates are Go text/templates. The messages
+// replace PREROLL_MESSAGE, BREAK_MESSAGES, END_OF_DAY_MESSAGE and CLOSING_MESSAGE:
+//
+//	{"room_info": {"title": "{{.Event.Title}}{{with .Event.Type}} ({{.}}){{end}}", "speaker": "{{speakers .Event.Speakers 2}}"},
+//	 "preroll": "Starting in {{.Minutes}} minutes", "breaks": {"15": "Break"}, "end_of_day": "See you tomorrow"}
+type TemplatesConfig struct {
+	RoomInfo map[string]string `json:"room_info"` // by RoomInfo JSON field (e.g. "title", "n_time", "auto_loop_sec")
+	Preroll  string            `json:"preroll"`
+	Breaks   map[string]string `json:"breaks"` // by minimum gap minutes
+	EndOfDay string            `json:"end_of_day"`
+	Closing  string            `json:"closing"`
+}
+
+// RoomInfoTemplates render the RoomInfo fields from a RoomInfoData
+type RoomInfoTemplates map[string]*template.Template
+
+// RoomInfoData is what the room_info templates see. Next is nil if there is no next event.
+type RoomInfoData struct {
+	Room  string
+	Event EventData
+	Next  *EventData
+}
+
+// EventData is an Event with its times in the venue timezone
+type EventData struct {
+	Event
+	Start    time.Time
+	End      time.Time
+	Time     string   // the default RoomInfo time, e.g. HH:MM
+	Speakers []string // names
+}
+
+// roomInfoFields are the RoomInfo fields that templates can render
+var roomInfoFields = map[string]func(info *RoomInfo, value string) error{
+	"room":      func(info *RoomInfo, value string) error { info.RoomName = value; return nil },
+	"title":     func(info *RoomInfo, value string) error { info.CurrentTitle = value; return nil },
+	"speaker":   func(info *RoomInfo, value string) error { info.CurrentSpeaker = value; return nil },
+	"time":      func(info *RoomInfo, value string) error { info.CurrentTime = value; return nil },
+	"n_title":   func(info *RoomInfo, value string) error { info.NextTitle = value; return nil },
+	"n_speaker": func(info *RoomInfo, value string) error { info.NextSpeaker = value; return nil },
+	"n_time":    func(info *RoomInfo, value string) error { info.NextTime = value; return nil },
+	"auto_loop_sec": func(info *RoomInfo, value string) error {
+		seconds, err := strconv.Atoi(strings.TrimSpace(value))
+		if err == nil {
+			info.AutoLoopSec = seconds
+		}
+		return err
+	},
+}
+
+// templateFuncs are the functions available to the templates
+var templateFuncs = template.FuncMap{
+	"join":     strings.Join,
+	"speakers": joinSpeakers,
+}
+
+// joinSpeakers joins the first max names, e.g. "A, B and 2 more". max 0 joins all of them.
+func joinSpeakers(names []string, max int) string {
+	if max <= 0 || len(names) <= max {
+		if len(names) > 1 {
+			return strings.Join(names[:len(names)-1], ", ") + " and " + names[len(names)-1]
+		}
+		return strings.Join(names, "")
+	}
+	return fmt.Sprintf("%v and %v more", strings.Join(names[:max], ", "), len(names)-max)
+}
+
+// LoadTemplates reads the templates file, sets the messages it has, and returns the
+// room_info templates. An empty filename means no templates (nil).
+func LoadTemplates(filename string) (RoomInfoTemplates, error) {
+	if filename == "" {
+		return nil, nil
+	}
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var config TemplatesConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("error parsing the templates %v: %v", filename, err)
+	}
+
+	templates := RoomInfoTemplates{}
+	for field, text := range config.RoomInfo {
+		if _, ok := roomInfoFields[field]; !ok {
+			return nil, fmt.Errorf("error in the templates %v: unknown room_info field %q", filename, field)
+		}
+		if templates[field], err = template.New(field).Funcs(templateFuncs).Parse(text); err != nil {
+			return nil, fmt.Errorf("error in the templates %v: %v", filename, err)
+		}
+	}
+
+	messages := []struct {
+		name   string
+		text   string
+		target **template.Template
+	}{
+		{"preroll", config.Preroll, &eventPreroll.Message},
+		{"end_of_day", config.EndOfDay, &endOfDayMessage},
+		{"closing", config.Closing, &closingMessage},
+	}
+	parsed := make([]*template.Template, len(messages))
+	for i, message := range messages {
+		if message.text == "" {
+			continue
+		}
+		if parsed[i], err = template.New(message.name).Funcs(templateFuncs).Parse(message.text); err != nil {
+			return nil, fmt.Errorf("error in the templates %v: %v", filename, err)
+		}
+	}
+	breaks, err := newBreaks(config.Breaks)
+	if err != nil {
+		return nil, fmt.Errorf("error in the templates %v: %v", filename, err)
+	}
+
+	// everything is valid: set the messages
+	for i, message := range messages {
+		if parsed[i] != nil {
+			*message.target = parsed[i]
+		}
+	}
+	if breaks != nil {
+		eventBreaks = breaks
+	}
+	return templates, nil
+}
+
+// loadTemplates loads TEMPLATES_FILE (see LoadTemplates) into roomInfoTemplates
+func loadTemplates() (err error) {
+	roomInfoTemplates, err = LoadTemplates(templatesFile)
+	return err
+}
+
+// newEventData returns the template data of event. Times are zero if its date is invalid.
+func newEventData(event Event, loc *time.Location) EventData {
+	data := EventData{Event: event, Time: eventDisplayTime(event, loc)}
+	if start, err := ParseEventTime(event.Date, loc); err == nil {
+		duration, _ := ParseCustomDuration(event.Duration)
+		data.Start, data.End = start.In(loc), start.Add(duration).In(loc)
+	}
+	for _, p := range event.Persons {
+		data.Speakers = append(data.Speakers, p.Name)
+	}
+	return data
+}
+
+// Apply renders the fields with a template into info. Fields that fail keep their value.
+// The next event fields are only rendered if there is a next event.
+func (t RoomInfoTemplates) Apply(info *RoomInfo, room Room, event, nextEvent Event, loc *time.Location) {
+	data := RoomInfoData{Room: room.Name, Event: newEventData(event, loc)}
+	if nextEvent.Title != "" {
+		next := newEventData(nextEvent, loc)
+		next.Time = info.NextTime
+		data.Next = &next
+	}
+
+	for field, tmpl := range t {
+		if strings.HasPrefix(field, "n_") && data.Next == nil {
+			continue
+		}
+		var value bytes.Buffer
+		if err := tmpl.Execute(&value, data); err != nil {
+			log.Printf("WARNING: Could not render the %v of %v. %v\n", field, event.Title, err)
+			continue
+		}
+		if err := roomInfoFields[field](info, value.String()); err != nil {
+			log.Printf("WARNING: Invalid %v of %v. %v\n", field, event.Title, err)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"text/template"
+	"time"
+)
+
+func TestJoinSpeakers(t *testing.T) {
+	for _, test := range []struct {
+		names    []string
+		max      int
+		expected string
+	}{
+		{nil, 2, ""},
+		{[]string{"A"}, 2, "A"},
+		{[]string{"A", "B"}, 2, "A and B"},
+		{[]string{"A", "B", "C"}, 0, "A, B and C"},
+		{[]string{"A", "B", "C", "D"}, 2, "A, B and 2 more"},
+	} {
+		if got := joinSpeakers(test.names, test.max); got != test.expected {
+			t.Errorf("Unexpected speakers for %v (max %v). Got: %q Expected: %q", test.names, test.max, got, test.expected)
+		}
+	}
+}
+
+func TestLoadTemplates(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "templates.json")
+	config := `{
+		"room_info": {
+			"title": "{{.Event.Title}}{{with .Event.Type}} ({{.}}){{end}}",
+			"speaker": "{{speakers .Event.Speakers 2}}",
+			"time": "{{.Event.Start.Format \"15:04\"}}-{{.Event.End.Format \"15:04\"}}",
+			"n_title": "{{.Next.Title}} in {{.Room}}",
+			"auto_loop_sec": "{{if .Next}}10{{else}}0{{end}}"
+		},
+		"preroll": "Starting in {{.Minutes}}'",
+		"breaks": {"30": "Coffee"}
+	}`
+	if err := ioutil.WriteFile(filename, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer func(p Preroll, b Breaks) { eventPreroll, eventBreaks, roomInfoTemplates = p, b, nil }(eventPreroll, eventBreaks)
+
+	templates, err := LoadTemplates(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(eventBreaks) != 1 || eventBreaks[0].MinMinutes != 30 || renderNotice(eventPreroll.Message, PrerollData{Minutes: 5}) != "Starting in 5'" {
+		t.Errorf("The messages should be set from the file. Got: %+v, %v", eventBreaks, eventPreroll.Message)
+	}
+
+	roomInfoTemplates = templates
+	lisbon, _ := time.LoadLocation("Europe/Lisbon")
+	room := Room{ID: 1, Name: "Room1"}
+	event := Event{Title: "Event1", Type: "Workshop", Date: "2019-10-10T10:00:00+01:00", Duration: "00:30",
+		Persons: []Person{{Name: "A"}, {Name: "B"}, {Name: "C"}, {Name: "D"}}}
+	next := Event{Title: "Event2", Date: "2019-10-10T11:00:00+01:00", Duration: "00:30"}
+
+	info := createRoomInfo(room, event, next, lisbon)
+	expected := RoomInfo{ID: 1, RoomName: "Room1", CurrentTitle: "Event1 (Workshop)", CurrentSpeaker: "A, B and 2 more",
+		CurrentTime: "10:00-10:30", NextTitle: "Event2 in Room1", NextTime: "11:00", AutoLoopSec: 10}
+	if info != expected {
+		t.Errorf("Unexpected RoomInfo\nGot:      %+v\nExpected: %+v", info, expected)
+	}
+	if info = createRoomInfo(room, next, Event{}, lisbon); info.NextTitle != "" || info.AutoLoopSec != 0 || info.CurrentTitle != "Event2" {
+		t.Errorf("Without a next event, its fields should be empty. Got: %+v", info)
+	}
+}
+
+func TestLoadTemplatesErrors(t *testing.T) {
+	if templates, err := LoadTemplates(""); templates != nil || err != nil {
+		t.Errorf("No file means no templates. Got: %v, %v", templates, err)
+	}
+
+	defer func(p Preroll) { eventPreroll = p }(eventPreroll)
+	message := template.Must(template.New("test").Parse("unchanged"))
+	eventPreroll.Message = message
+	for _, config := range []string{
+		`{"room_info": {"subtitle": "{{.Event.Title}}"}}`,
+		`{"room_info": {"title": "{{.Event.Title"}}`,
+		`{"preroll": "Starting {{if", "room_info": {"title": "{{.Event.Title}}"}}`,
+		`{"preroll": "Starting soon", "breaks": {"lunch": "Lunch"}}`,
+		`{"room_info": []}`,
+	} {
+		filename := filepath.Join(t.TempDir(), "templates.json")
+		ioutil.WriteFile(filename, []byte(config), 0644)
+		if _, err := LoadTemplates(filename); err == nil {
+			t.Errorf("%v should fail", config)
+		}
+	}
+	if eventPreroll.Message != message {
+		t.Error("Invalid files should not change the messages")
+	}
+}
+
+func TestTemplatesInRoomUpdates(t *testing.T) {
+	defer func(b Breaks, endOfDay, closing *template.Template) {
+		eventBreaks, endOfDayMessage, closingMessage, roomInfoTemplates = b, endOfDay, closing, nil
+	}(eventBreaks, endOfDayMessage, closingMessage)
+	eventBreaks, _ = ParseBreaks("30=Break")
+	endOfDayMessage = template.Must(template.New("end").Parse("See you tomorrow"))
+	closingMessage = template.Must(template.New("closing").Parse("Thank you!"))
+	roomInfoTemplates = RoomInfoTemplates{
+		"room":          template.Must(template.New("room").Parse("{{.Room}} (Floor 1)")),
+		"auto_loop_sec": template.Must(template.New("auto_loop_sec").Parse("20")),
+	}
+
+	schedule := Schedule{Days: []Day{
+		{Date: "2019-10-10", Rooms: []Room{{ID: 1, Name: "Room1", Events: []Event{
+			{GUID: "abc1", Title: "Event1", Date: "2019-10-10T10:00:00Z", Duration: "00:30"},
+			{GUID: "abc2", Title: "Event2", Date: "2019-10-10T11:00:00Z", Duration: "00:30"},
+		}}}},
+		{Date: "2019-10-11", Rooms: []Room{{ID: 1, Name: "Room1", Events: []Event{
+			{GUID: "efg1", Title: "Event3", Date: "2019-10-11T10:00:00Z", Duration: "00:30"},
+		}}}},
+	}}
+	jobs := planEventUpdatesAt(schedule, time.Date(2019, 10, 10, 9, 0, 0, 0, time.UTC))
+	phases := make(map[string]bool)
+	for _, job := range jobs {
+		phases[job.Info.Phase] = true
+		if job.Info.RoomName != "Room1 (Floor 1)" || job.Info.AutoLoopSec != 20 {
+			t.Errorf("The %v update should keep the room templates. Got: %q %v", job.Key, job.Info.RoomName, job.Info.AutoLoopSec)
+		}
+	}
+	if !phases[phaseBreak] || !phases[phaseEnded] || !phases[phaseClosed] {
+		t.Errorf("Expected break, end of day and closing updates. Got: %+v", jobs)
+	}
+}
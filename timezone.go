@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+	_ "time/tzdata" // the venue timezone must load even without system zoneinfo
+)
+
+var conferenceTimezone = GetEnv("CONFERENCE_TIMEZONE", "") // e.g. "Europe/Lisbon". Overrides the schedule one
+
+// eventTimeLayouts are the accepted Event.Date (and Day.Start) formats with a zone
+var eventTimeLayouts = []string{
+	time.RFC3339Nano, // also accepts "Z", and no fractional seconds
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02 15:04:05Z07:00",
+	"2006-01-02 15:04Z07:00",
+}
+
+// naiveEventTimeLayouts are the accepted formats without a zone (venue local time)
+var naiveEventTimeLayouts = []string{
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+}
+
+// venueLocation returns the conference timezone: CONFERENCE_TIMEZONE, or the
+// schedule time_zone_name, or the local timezone.
+func venueLocation(conference Conference) *time.Location {
+	for _, name := range []string{conferenceTimezone, conference.TimeZoneName} {
+		if name == "" {
+			continue
+		}
+		loc, err := time.LoadLocation(name)
+		if err != nil {
+			log.Printf("WARNING: Unknown timezone %q: %v\n", name, err)
+			continue
+		}
+		return loc
+	}
+	return time.Local
+}
+
+// ParseEventTime parses an Event.Date (or Day.Start). Dates without a zone are
+// in the venue location loc.
+func ParseEventTime(date string, loc *time.Location) (time.Time, error) {
+	for _, layout := range eventTimeLayouts {
+		if t, err := time.Parse(layout, date); err == nil {
+			return t, nil
+		}
+	}
+	for _, layout := range naiveEventTimeLayouts {
+		if t, err := time.ParseInLocation(layout, date, loc); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("error: invalid event date %q. Expected RFC3339 (e.g. 2019-10-10T10:00:00+01:00) or 2019-10-10T10:00:00", date)
+}
+
+// eventDisplayTime renders the event start as HH:MM in the venue location.
+// It falls back to Event.Start if the date can not be parsed.
+func eventDisplayTime(event Event, loc *time.Location) string {
+	t, err := ParseEventTime(event.Date, loc)
+	if err != nil {
+		return event.Start
+	}
+	return t.In(loc).Format("15:04")
+}
+
+// nextEventDisplayTime renders the start of nextEvent as HH:MM when it is on the day of event.
+// Otherwise the weekday ("Fri 10:00") or, a week or more later, the date ("2019-10-18 10:00") comes first.
+func nextEventDisplayTime(event, nextEvent Event, loc *time.Location) string {
+	start, err := ParseEventTime(event.Date, loc)
+	nextStart, nextErr := ParseEventTime(nextEvent.Date, loc)
+	if err != nil || nextErr != nil || eventDay(start, loc) == eventDay(nextStart, loc) {
+		return eventDisplayTime(nextEvent, loc)
+	}
+
+	day, _ := time.Parse("2006-01-02", eventDay(start, loc))
+	nextDay, _ := time.Parse("2006-01-02", eventDay(nextStart, loc))
+	if nextDay.Sub(day) < 7*24*time.Hour {
+		return nextStart.In(loc).Format("Mon 15:04")
+	}
+	return nextStart.In(loc).Format("2006-01-02 15:04")
+}
+
+// eventDay returns the day of t in the venue location, as 2006-01-02
+func eventDay(t time.Time, loc *time.Location) string {
+	return t.In(loc).Format("2006-01-02")
+}
+
+// validEvents returns the events with a valid date, logging the rejected ones
+func validEvents(events []Event, loc *time.Location) []Event {
+	valid := make([]Event, 0, len(events))
+	for _, event := range events {
+		if _, err := ParseEventTime(event.Date, loc); err != nil {
+			log.Printf("ERROR: Rejecting event %v (%v): %v\n", event.ID, event.Title, err)
+			continue
+		}
+		valid = append(valid, event)
+	}
+	return valid
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseEventTime(t *testing.T) {
+	lisbon, _ := time.LoadLocation("Europe/Lisbon")
+	expected := time.Date(2019, 10, 10, 9, 0, 0, 0, time.UTC)
+
+	for _, date := range []string{
+		"2019-10-10T10:00:00+01:00",
+		"2019-10-10T09:00:00Z",
+		"2019-10-10T09:00:00.000Z",
+		"2019-10-10T10:00+01:00",
+		"2019-10-10 10:00:00+01:00",
+		"2019-10-10T10:00:00", // naive, in the venue timezone (WEST in October)
+		"2019-10-10 10:00",
+	} {
+		got, err := ParseEventTime(date, lisbon)
+		if err != nil {
+			t.Errorf("Unexpected error for %v: %v", date, err)
+		} else if !got.Equal(expected) {
+			t.Errorf("Unexpected time for %v. Got: %v Expected: %v", date, got, expected)
+		}
+	}
+
+	for _, date := range []string{"", "10:00", "2019-10-10", "10/10/2019 10:00"} {
+		if _, err := ParseEventTime(date, lisbon); err == nil {
+			t.Errorf("An error was expected for %q", date)
+		}
+	}
+}
+
+func TestVenueLocation(t *testing.T) {
+	if loc := venueLocation(Conference{TimeZoneName: "Europe/Lisbon"}); loc.String() != "Europe/Lisbon" {
+		t.Errorf("Expected the schedule timezone. Got: %v", loc)
+	}
+	if loc := venueLocation(Conference{TimeZoneName: "Nowhere/Invalid"}); loc != time.Local {
+		t.Errorf("Expected the local timezone for an invalid one. Got: %v", loc)
+	}
+}
+
+func TestEventDisplayTime(t *testing.T) {
+	lisbon, _ := time.LoadLocation("Europe/Lisbon")
+
+	// the schedule exported in UTC is shown in the venue time
+	if got := eventDisplayTime(Event{Date: "2019-10-10T09:00:00Z", Start: "09:00"}, lisbon); got != "10:00" {
+		t.Errorf("Expected the venue local time. Got: %v", got)
+	}
+	if got := eventDisplayTime(Event{Start: "11:00"}, lisbon); got != "11:00" {
+		t.Errorf("Expected Event.Start as fallback. Got: %v", got)
+	}
+}
+
+func TestPlanEventUpdatesRejectsInvalidDates(t *testing.T) {
+	tomorrow := time.Now().Add(24 * time.Hour)
+	schedule := Schedule{
+		Days: []Day{
+			Day{
+				Rooms: []Room{
+					Room{
+						ID: 1,
+						Events: []Event{
+							Event{GUID: "a", Title: "Valid", Date: tomorrow.Format(time.RFC3339), Duration: "00:30"},
+							Event{GUID: "b", Title: "Invalid", Date: "tomorrow", Duration: "00:30"},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	jobs := planEventUpdates(schedule)
+	if len(jobs) != 1 || jobs[0].Info.CurrentTitle != "Valid" || jobs[0].Info.NextTitle != "" {
+		t.Errorf("Only the valid event should be planned. Got: %+v", jobs)
+	}
+}
+
+func TestNextEventDisplayTime(t *testing.T) {
+	lisbon, _ := time.LoadLocation("Europe/Lisbon")
+	event := Event{Date: "2019-10-10T18:00:00+01:00"}
+
+	for _, test := range []struct {
+		next, expected string
+	}{
+		{"2019-10-10T19:00:00+01:00", "19:00"},
+		{"2019-10-11T10:00:00+01:00", "Fri 10:00"},
+		{"2019-10-11T00:30:00+01:00", "Fri 00:30"},
+		{"2019-10-16T10:00:00+01:00", "Wed 10:00"},
+		{"2019-10-17T10:00:00+01:00", "2019-10-17 10:00"},
+	} {
+		if got := nextEventDisplayTime(event, Event{Date: test.next}, lisbon); got != test.expected {
+			t.Errorf("Unexpected time for %v. Got: %v Expected: %v", test.next, got, test.expected)
+		}
+	}
+
+	if got := nextEventDisplayTime(Event{Start: "18:00"}, Event{Start: "10:00"}, lisbon); got != "10:00" {
+		t.Errorf("Events without dates should show their start. Got: %v", got)
+	}
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+const (
+	severityError   = "error"
+	severityWarning = "warning"
+)
+
+// ValidationIssue is a schedule problem found by ValidateSchedule
+type ValidationIssue struct {
+	Severity string `json:"severity"` // "error" or "warning"
+	Check    string `json:"check"`    // e.g. "invalid-date"
+	Day      string `json:"day,omitempty"`
+	Room     string `json:"room,omitempty"`
+	Event    string `json:"event,omitempty"`
+	Message  string `json:"message"`
+}
+
+// ValidationReport is the result of validating the loaded schedule
+type ValidationReport struct {
+	Conference string            `json:"conference"`
+	Sources    []string          `json:"sources"`
+	Days       int               `json:"days"`
+	Rooms      int               `json:"rooms"`
+	Events     int               `json:"events"`
+	Errors     int               `json:"errors"`
+	Warnings   int               `json:"warnings"`
+	Issues     []ValidationIssue `json:"issues"`
+}
+
+func (r *ValidationReport) add(issue ValidationIssue) {
+	if issue.Severity == severityError {
+		r.Errors++
+	} else {
+		r.Warnings++
+	}
+	r.Issues = append(r.Issues, issue)
+}
+
+// ValidateSchedule checks the loaded schedule: the sources, the rooms mapping, the merge
+// conflicts and every event (date, duration, day bounds, title and speakers)
+func ValidateSchedule(load ScheduleLoad) ValidationReport {
+	schedule := load.Schedule
+	report := ValidationReport{
+		Conference: schedule.Conference.Title,
+		Sources:    load.Sources,
+		Days:       len(schedule.Days),
+		Issues:     []ValidationIssue{},
+	}
+
+	if load.Fallback != nil {
+		report.add(ValidationIssue{Severity: severityWarning, Check: "source-fallback",
+			Message: fmt.Sprintf("%v failed, validated the cached %v instead: %v", load.Sources[0], altLocalScheduleFile, load.Fallback)})
+	}
+	for _, skipped := range load.Skipped {
+		report.add(ValidationIssue{Severity: severityError, Check: "source-error",
+			Message: fmt.Sprintf("%v: %v", skipped.Source, skipped.Err)})
+	}
+	if !load.Mapped {
+		report.add(ValidationIssue{Severity: severityWarning, Check: "unmapped-room",
+			Message: "ROOMS_FILE is not set, so room IDs follow the order of the schedule"})
+	}
+	for _, room := range load.Unmapped {
+		report.add(ValidationIssue{Severity: severityWarning, Check: "unmapped-room", Room: room.Name,
+			Message: fmt.Sprintf("not in %v, got ID %v", roomsFile, room.ID)})
+	}
+
+	loc := venueLocation(schedule.Conference)
+	rooms := make(map[int]bool)
+	for _, day := range schedule.Days {
+		dayStart, dayEnd := validateDayBounds(&report, day, loc)
+		for _, room := range day.Rooms {
+			rooms[room.ID] = true
+			for _, event := range room.Events {
+				report.Events++
+				validateEvent(&report, day, room, event, dayStart, dayEnd, loc)
+			}
+		}
+	}
+	report.Rooms = len(rooms)
+
+	for _, conflict := range load.Conflicts {
+		issue := ValidationIssue{Severity: severityError, Check: conflict.Kind, Day: conflict.Day, Room: conflict.Room,
+			Event: eventLabel(conflict.Other), Message: conflict.String()}
+		if conflict.Kept.GUID != "" && conflict.Kept.GUID == conflict.Other.GUID {
+			issue.Check = "duplicate-guid"
+		}
+		if conflict.Dropped {
+			issue.Severity = severityWarning // solved by the source priority
+		}
+		report.add(issue)
+	}
+
+	sort.SliceStable(report.Issues, func(i, j int) bool {
+		return report.Issues[i].Severity == severityError && report.Issues[j].Severity != severityError
+	})
+	return report
+}
+
+// validateDayBounds parses the day start/end. Zero times are not checked.
+func validateDayBounds(report *ValidationReport, day Day, loc *time.Location) (start, end time.Time) {
+	for _, bound := range []struct {
+		value string
+		t     *time.Time
+	}{{day.Start, &start}, {day.End, &end}} {
+		if bound.value == "" {
+			continue
+		}
+		t, err := ParseEventTime(bound.value, loc)
+		if err != nil {
+			report.add(ValidationIssue{Severity: severityWarning, Check: "invalid-day", Day: day.Date, Message: err.Error()})
+			continue
+		}
+		*bound.t = t
+	}
+	return start, end
+}
+
+func validateEvent(report *ValidationReport, day Day, room Room, event Event, dayStart, dayEnd time.Time, loc *time.Location) {
+	issue := func(severity, check, message string) {
+		report.add(ValidationIssue{Severity: severity, Check: check, Day: day.Date, Room: room.Name, Event: eventLabel(event), Message: message})
+	}
+
+	if strings.TrimSpace(event.Title) == "" {
+		issue(severityError, "empty-title", "the event has no title")
+	}
+	if len(event.Persons) == 0 {
+		issue(severityWarning, "missing-speakers", "the event has no speakers")
+	}
+
+	start, dateErr := ParseEventTime(event.Date, loc)
+	if dateErr != nil {
+		issue(severityError, "invalid-date", dateErr.Error())
+	}
+	duration, durationErr := ParseCustomDuration(event.Duration)
+	if durationErr != nil {
+		issue(severityError, "invalid-duration", durationErr.Error())
+	}
+	if dateErr != nil {
+		return
+	}
+
+	if !dayStart.IsZero() && start.Before(dayStart) {
+		issue(severityError, "outside-day", fmt.Sprintf("starts at %v, before the day start %v", event.Date, day.Start))
+	}
+	if end := start.Add(duration); durationErr == nil && !dayEnd.IsZero() && end.After(dayEnd) {
+		issue(severityError, "outside-day", fmt.Sprintf("ends at %v, after the day end %v", end.In(loc).Format(eventDateLayout), day.End))
+	}
+}
+
+func eventLabel(event Event) string {
+	if event.GUID == "" {
+		return event.Title
+	}
+	return fmt.Sprintf("%v [%v]", event.Title, event.GUID)
+}
+
+// WriteText writes the report as a table
+func (r ValidationReport) WriteText(w io.Writer) {
+	fmt.Fprintf(w, "%v: %v sources, %v days, %v rooms, %v events\n\n", r.Conference, len(r.Sources), r.Days, r.Rooms, r.Events)
+	if len(r.Issues) > 0 {
+		table := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(table, "SEVERITY\tCHECK\tDAY\tROOM\tEVENT\tMESSAGE")
+		for _, issue := range r.Issues {
+			fmt.Fprintf(table, "%v\t%v\t%v\t%v\t%v\t%v\n", issue.Severity, issue.Check, issue.Day, issue.Room, issue.Event, issue.Message)
+		}
+		table.Flush()
+		fmt.Fprintln(w)
+	}
+	fmt.Fprintf(w, "%v errors, %v warnings\n", r.Errors, r.Warnings)
+}
+
+func validateCommand(args []string) int {
+	flags := flag.NewFlagSet("validate", flag.ContinueOnError)
+	format := flags.String("format", "text", "report format: text or json")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *format != "text" && *format != "json" {
+		fmt.Fprintf(os.Stderr, "invalid -format %q: expected text or json\n", *format)
+		return 2
+	}
+
+	var report ValidationReport
+	keepScheduleCache() // a schedule being validated is not the last good one yet
+	load, err := loadSources()
+	if err != nil {
+		report = ValidationReport{Sources: strings.Split(scheduleEventURL, ","), Issues: []ValidationIssue{}}
+		report.add(ValidationIssue{Severity: severityError, Check: "source-error", Message: err.Error()})
+	} else {
+		report = ValidateSchedule(load)
+	}
+	if err := loadTemplates(); err != nil {
+		report.add(ValidationIssue{Severity: severityError, Check: "invalid-templates", Message: err.Error()})
+	}
+
+	if *format == "json" {
+		data, _ := json.MarshalIndent(report, "", "  ")
+		fmt.Println(string(data))
+	} else {
+		report.WriteText(os.Stdout)
+	}
+	if report.Errors > 0 {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidateSchedule(t *testing.T) {
+	persons := []Person{{Name: "PersonName1"}}
+	load := ScheduleLoad{
+		Sources:  []string{"main", "extra"},
+		Mapped:   true,
+		Unmapped: []Room{{ID: 3, Name: "New Room"}},
+		Skipped:  []SourceError{{Source: "broken", Err: errors.New("unexpected status 500")}},
+		Schedule: Schedule{
+			Conference: Conference{Title: "Conference Title", TimeZoneName: "Europe/Lisbon"},
+			Days: []Day{{Date: "2019-10-10", Start: "2019-10-10T04:00:00", End: "2019-10-11T03:59:00", Rooms: []Room{
+				{ID: 1, Name: "Room1", Events: []Event{
+					{GUID: "abc1", Title: "Event1", Date: "2019-10-10T10:00:00+01:00", Duration: "00:30", Persons: persons},
+					{GUID: "abc2", Title: "", Date: "2019-10-10T11:00:00+01:00", Duration: "00:30", Persons: persons},
+					{GUID: "abc3", Title: "Event3", Date: "10/10/2019 12:00", Duration: "1h", Persons: persons},
+					{GUID: "abc4", Title: "Event4", Date: "2019-10-11T03:30:00+01:00", Duration: "01:00"},
+				}},
+			}}},
+		},
+		Conflicts: []MergeConflict{
+			{Kind: "duplicate", Kept: Event{GUID: "abc1"}, Other: Event{GUID: "abc1"}, Dropped: false},
+			{Kind: "overlap", Kept: Event{Title: "Event1"}, Other: Event{Title: "Workshop"}, Dropped: true},
+		},
+	}
+
+	report := ValidateSchedule(load)
+
+	expected := map[string]string{
+		"source-error":     severityError,
+		"empty-title":      severityError,
+		"invalid-date":     severityError,
+		"invalid-duration": severityError,
+		"outside-day":      severityError,
+		"duplicate-guid":   severityError,
+		"overlap":          severityWarning,
+		"missing-speakers": severityWarning,
+		"unmapped-room":    severityWarning,
+	}
+	got := make(map[string]string)
+	for _, issue := range report.Issues {
+		got[issue.Check] = issue.Severity
+	}
+	for check, severity := range expected {
+		if got[check] != severity {
+			t.Errorf("Expected a %v %v issue. Got: %v", severity, check, got[check])
+		}
+	}
+	if len(got) != len(expected) || report.Errors != 6 || report.Warnings != 3 || report.Events != 4 || report.Rooms != 1 {
+		t.Errorf("Unexpected report (%v errors, %v warnings): %+v", report.Errors, report.Warnings, report.Issues)
+	}
+	if report.Issues[0].Severity != severityError || report.Issues[len(report.Issues)-1].Severity != severityWarning {
+		t.Error("Errors should be listed first")
+	}
+
+	var text bytes.Buffer
+	report.WriteText(&text)
+	if !strings.Contains(text.String(), "invalid-date") || !strings.HasSuffix(text.String(), "6 errors, 3 warnings\n") {
+		t.Errorf("Unexpected text report:\n%v", text.String())
+	}
+}
+
+func TestValidateCommand(t *testing.T) {
+	defer func(fetchers []*ScheduleFetcher) { scheduleFetchers = fetchers }(scheduleFetchers)
+
+	filename := filepath.Join(t.TempDir(), "schedule.csv")
+	scheduleFetchers = newScheduleFetchers([]string{filename})
+
+	ioutil.WriteFile(filename, []byte("date,start,duration,room,title,speakers\n2019-10-10,10:00,30,Hall,Event1,PersonName1\n"), 0644)
+	if code := validateCommand([]string{"-format", "json"}); code != 0 {
+		t.Errorf("A valid schedule should exit with 0. Got: %v", code)
+	}
+
+	ioutil.WriteFile(filename, []byte("date,start,duration,room,title,speakers\n2019-10-10,10:00,30,Hall,Event1,PersonName1\n2019-10-10,10:15,30,Hall,Event2,PersonName1\n"), 0644)
+	if code := validateCommand(nil); code != 1 {
+		t.Errorf("Overlapping events should exit with 1. Got: %v", code)
+	}
+
+	if code := validateCommand([]string{"-format", "xml"}); code != 2 {
+		t.Errorf("An invalid format should exit with 2. Got: %v", code)
+	}
+}
+
+func TestValidateCommandKeepsTheCache(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(fetcherTestXML))
+	}))
+	defer server.Close()
+	defer func(fetchers []*ScheduleFetcher) { scheduleFetchers = fetchers }(scheduleFetchers)
+
+	cacheFile := filepath.Join(t.TempDir(), "schedule.xml")
+	ioutil.WriteFile(cacheFile, []byte("last good schedule"), 0644)
+	scheduleFetchers = []*ScheduleFetcher{NewScheduleFetcher(server.URL, cacheFile)}
+
+	validateCommand([]string{"-format", "json"})
+	if cached, _ := ioutil.ReadFile(cacheFile); string(cached) != "last good schedule" {
+		t.Errorf("Validating should not change the cache file. Got: %v", string(cached))
+	}
+	if _, err := os.Stat(cacheFile + ".format"); !os.IsNotExist(err) {
+		t.Errorf("Validating should not write the cache format. Got: %v", err)
+	}
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WebSocket opcodes (RFC 6455)
+const (
+	wsText  = 0x1
+	wsClose = 0x8
+	wsPing  = 0x9
+	wsPong  = 0xA
+)
+
+const wsAcceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// webSocket is a minimal server side WebSocket: it writes text messages,
+// and only reads to answer pings and close frames
+type webSocket struct {
+	conn         net.Conn
+	reader       *bufio.Reader
+	mu           sync.Mutex    // serializes writes
+	WriteTimeout time.Duration // so a stalled client does not block the writer. 0 waits forever
+}
+
+// wsAccept computes the Sec-WebSocket-Accept value for the key
+func wsAccept(key string) string {
+	sum := sha1.Sum([]byte(key + wsAcceptGUID))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+func headerContains(header http.Header, name, value string) bool {
+	for _, v := range strings.Split(header.Get(name), ",") {
+		if strings.EqualFold(strings.TrimSpace(v), value) {
+			return true
+		}
+	}
+	return false
+}
+
+// upgradeWebSocket does the opening handshake, and takes over the connection
+func upgradeWebSocket(w http.ResponseWriter, r *http.Request) (*webSocket, error) {
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if r.Method != http.MethodGet || !headerContains(r.Header, "Connection", "upgrade") ||
+		!headerContains(r.Header, "Upgrade", "websocket") || key == "" {
+		http.Error(w, "expected a WebSocket handshake", http.StatusBadRequest)
+		return nil, fmt.Errorf("error: not a WebSocket handshake")
+	}
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "WebSocket not supported", http.StatusInternalServerError)
+		return nil, fmt.Errorf("error: connection can not be hijacked")
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, err
+	}
+
+	response := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + wsAccept(key) + "\r\n\r\n"
+	if _, err := conn.Write([]byte(response)); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &webSocket{conn: conn, reader: rw.Reader}, nil
+}
+
+// WriteText sends a text message
+func (ws *webSocket) WriteText(data []byte) error {
+	return ws.writeFrame(wsText, data)
+}
+
+// Ping sends a ping, keeping idle connections alive through proxies
+func (ws *webSocket) Ping() error {
+	return ws.writeFrame(wsPing, nil)
+}
+
+func (ws *webSocket) writeFrame(opcode byte, payload []byte) error {
+	header := []byte{0x80 | opcode} // FIN, no fragmentation
+	switch length := len(payload); {
+	case length < 126:
+		header = append(header, byte(length))
+	case length <= 0xFFFF:
+		header = append(header, 126, 0, 0)
+		binary.BigEndian.PutUint16(header[2:], uint16(length))
+	default:
+		header = append(header, 127, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(header[2:], uint64(length))
+	}
+
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	if ws.WriteTimeout > 0 {
+		ws.conn.SetWriteDeadline(time.Now().Add(ws.WriteTimeout))
+	}
+	_, err := ws.conn.Write(append(header, payload...))
+	return err
+}
+
+// readFrame reads a (masked) client frame
+func (ws *webSocket) readFrame() (opcode byte, payload []byte, err error) {
+	var header [2]byte
+	if _, err = io.ReadFull(ws.reader, header[:]); err != nil {
+		return 0, nil, err
+	}
+	opcode = header[0] & 0x0F
+	masked := header[1]&0x80 != 0
+
+	length := uint64(header[1] & 0x7F)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err = io.ReadFull(ws.reader, ext[:]); err != nil {
+			return 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err = io.ReadFull(ws.reader, ext[:]); err != nil {
+			return 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+	if length > 1<<20 {
+		return 0, nil, fmt.Errorf("error: WebSocket frame too big (%v bytes)", length)
+	}
+
+	var mask [4]byte
+	if masked {
+		if _, err = io.ReadFull(ws.reader, mask[:]); err != nil {
+			return 0, nil, err
+		}
+	}
+	payload = make([]byte, length)
+	if _, err = io.ReadFull(ws.reader, payload); err != nil {
+		return 0, nil, err
+	}
+	if masked {
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+	}
+	return opcode, payload, nil
+}
+
+// ReadUntilClose discards client messages, answering pings, until the connection is closed
+func (ws *webSocket) ReadUntilClose() {
+	for {
+		opcode, payload, err := ws.readFrame()
+		if err != nil {
+			return
+		}
+		switch opcode {
+		case wsPing:
+			ws.writeFrame(wsPong, payload)
+		case wsClose:
+			ws.writeFrame(wsClose, payload)
+			return
+		}
+	}
+}
+
+// Close closes the underlying connection
+func (ws *webSocket) Close() error {
+	return ws.conn.Close()
+}